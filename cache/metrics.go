@@ -0,0 +1,62 @@
+package cache
+
+import (
+	"github.com/iTchTheRightSpot/utility/utils"
+)
+
+type instrumentedCache[K any, V any] struct {
+	ICache[K, V]
+	name    string
+	hits    *utils.CounterVec
+	misses  *utils.CounterVec
+	entries *utils.GaugeVec
+}
+
+// InstrumentedCache records hits, misses and, for caches reporting a
+// Length, the number of entries of c labeled by name. A nil reg defaults to
+// utils.DefaultRegistry.
+func InstrumentedCache[K any, V any](c ICache[K, V], name string, reg *utils.Registry) ICache[K, V] {
+	if reg == nil {
+		reg = utils.DefaultRegistry
+	}
+	return &instrumentedCache[K, V]{
+		ICache:  c,
+		name:    name,
+		hits:    reg.Counter("cache_hits_total", "Cache lookups that found a value.", "cache"),
+		misses:  reg.Counter("cache_misses_total", "Cache lookups that found nothing.", "cache"),
+		entries: reg.Gauge("cache_entries", "Entries held by a cache.", "cache"),
+	}
+}
+
+func (dep *instrumentedCache[K, V]) Put(key K, value V) {
+	dep.ICache.Put(key, value)
+	dep.size()
+}
+
+func (dep *instrumentedCache[K, V]) Get(key K) *V {
+	v := dep.ICache.Get(key)
+	if v == nil {
+		dep.misses.Inc(dep.name)
+	} else {
+		dep.hits.Inc(dep.name)
+	}
+	// expired entries leave without a call so refresh on reads too
+	dep.size()
+	return v
+}
+
+func (dep *instrumentedCache[K, V]) Delete(key K) {
+	dep.ICache.Delete(key)
+	dep.size()
+}
+
+func (dep *instrumentedCache[K, V]) Clear() {
+	dep.ICache.Clear()
+	dep.size()
+}
+
+func (dep *instrumentedCache[K, V]) size() {
+	if l, ok := dep.ICache.(interface{ Length() int }); ok {
+		dep.entries.Set(float64(l.Length()), dep.name)
+	}
+}
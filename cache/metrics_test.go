@@ -0,0 +1,40 @@
+package cache
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/iTchTheRightSpot/utility/utils"
+)
+
+func TestInstrumentedCache(t *testing.T) {
+	t.Parallel()
+
+	t.Run("should record cache hits and misses", func(t *testing.T) {
+		t.Parallel()
+
+		// given
+		reg := utils.MetricsRegistry()
+		c := InstrumentedCache(SyncMapInMemoryCache[string, int](utils.DevLogger("UTC"), 1, 10), "sessions", reg)
+
+		// method to test
+		c.Put("a", 1)
+		c.Get("a")
+		c.Get("b")
+
+		// assert
+		w := httptest.NewRecorder()
+		utils.MetricsHandler(reg).ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+		for _, line := range []string{
+			`cache_hits_total{cache="sessions"} 1`,
+			`cache_misses_total{cache="sessions"} 1`,
+			`cache_entries{cache="sessions"} 1`,
+		} {
+			if !strings.Contains(w.Body.String(), line) {
+				t.Errorf("expect %s, given\n%s", line, w.Body.String())
+			}
+		}
+	})
+}
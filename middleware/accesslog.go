@@ -0,0 +1,79 @@
+package middleware
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/iTchTheRightSpot/utility/utils"
+)
+
+type AccessLogFormat int
+
+const (
+	// AccessLogStructured logs "request completed" with the request as fields.
+	AccessLogStructured AccessLogFormat = iota
+	// AccessLogCombined logs the request as an Apache Combined Log Format line.
+	AccessLogCombined
+)
+
+// combinedTimeFormat is the %t layout of Apache access logs.
+const combinedTimeFormat = "02/Jan/2006:15:04:05 -0700"
+
+// access logs the completed request r answered through w. The http_status
+// field is kept in both formats so sampling never drops 5xx requests. It is
+// not called status as that key holds the level of every entry.
+func (dep *Middleware) access(r *http.Request, w *logWriter, start time.Time) {
+	latency := dep.Logger.Date().Sub(start)
+
+	if dep.AccessLogFormat == AccessLogCombined {
+		dep.Logger.Log(r.Context(), combined(r, w, dep.clientIP(r), start), utils.Int("http_status", w.code))
+		return
+	}
+
+	args := []interface{}{
+		"request completed",
+		utils.Int("http_status", w.code),
+		utils.Int64("bytes", w.bytes),
+		utils.Float64("latency_ms", float64(latency.Microseconds())/1000),
+		utils.String("proto", r.Proto),
+	}
+	for _, f := range []utils.Field{
+		utils.String("route", r.Pattern),
+		utils.String("query", r.URL.RawQuery),
+		utils.String("user_agent", r.UserAgent()),
+		utils.String("referer", r.Referer()),
+	} {
+		if f.Value != "" {
+			args = append(args, f)
+		}
+	}
+	dep.Logger.Log(r.Context(), args...)
+}
+
+// combined renders %h %l %u %t "%r" %>s %b "%{Referer}i" "%{User-Agent}i".
+func combined(r *http.Request, w *logWriter, ip string, start time.Time) string {
+	user := "-"
+	if u, _, ok := r.BasicAuth(); ok && u != "" {
+		user = u
+	}
+	size := "-"
+	if w.bytes > 0 {
+		size = strconv.FormatInt(w.bytes, 10)
+	}
+	uri := r.RequestURI
+	if uri == "" {
+		uri = r.URL.RequestURI()
+	}
+	return fmt.Sprintf("%s - %s [%s] %q %d %s %q %q",
+		orDash(ip), user, start.Format(combinedTimeFormat),
+		r.Method+" "+uri+" "+r.Proto, w.code, size, orDash(r.Referer()), orDash(r.UserAgent()))
+}
+
+func orDash(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
@@ -0,0 +1,95 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"testing"
+
+	"github.com/iTchTheRightSpot/utility/utils"
+)
+
+func TestAccessLog(t *testing.T) {
+	t.Parallel()
+
+	handler := func() http.Handler {
+		mux := http.NewServeMux()
+		mux.HandleFunc("GET /api/orders/{id}", func(w http.ResponseWriter, r *http.Request) {
+			_, _ = w.Write([]byte("hello "))
+			_, _ = w.Write([]byte("world"))
+		})
+		return mux
+	}
+
+	request := func() *http.Request {
+		req := httptest.NewRequest(http.MethodGet, "/api/orders/42?expand=items", nil)
+		req.RemoteAddr = "203.0.113.7:5555"
+		req.Header.Set("User-Agent", "curl/8.5.0")
+		req.Header.Set("Referer", "https://example.com/")
+		return req
+	}
+
+	t.Run("should log request as structured fields", func(t *testing.T) {
+		t.Parallel()
+
+		// given
+		lg := utils.RecordingLogger()
+		m := &Middleware{Logger: lg}
+
+		// method to test
+		m.Log(handler()).ServeHTTP(httptest.NewRecorder(), request())
+
+		// assert
+		entries := lg.Find(utils.Query{Message: "request completed"})
+		if len(entries) != 1 {
+			t.Errorf("expect 1 access entry, given %d", len(entries))
+			t.FailNow()
+		}
+		fields := map[string]interface{}{}
+		for _, f := range entries[0].Fields {
+			fields[f.Key] = f.Value
+		}
+		expect := map[string]interface{}{
+			"http_status": http.StatusOK,
+			"bytes":       int64(11),
+			"proto":       "HTTP/1.1",
+			"route":       "GET /api/orders/{id}",
+			"query":       "expand=items",
+			"user_agent":  "curl/8.5.0",
+			"referer":     "https://example.com/",
+		}
+		for k, v := range expect {
+			if fields[k] != v {
+				t.Errorf("expect %s %v, given %v", k, v, fields[k])
+			}
+		}
+		if _, ok := fields["status"]; ok {
+			t.Errorf("expect no status field clashing with the level, given %v", fields["status"])
+		}
+		if _, ok := fields["latency_ms"].(float64); !ok {
+			t.Errorf("expect latency_ms in milliseconds, given %v", fields["latency_ms"])
+		}
+	})
+
+	t.Run("should log apache combined format", func(t *testing.T) {
+		t.Parallel()
+
+		// given
+		lg := utils.RecordingLogger()
+		m := &Middleware{Logger: lg, AccessLogFormat: AccessLogCombined}
+
+		// method to test
+		m.Log(handler()).ServeHTTP(httptest.NewRecorder(), request())
+
+		// assert
+		entries := lg.Entries()
+		if len(entries) != 1 {
+			t.Errorf("expect 1 access entry, given %d", len(entries))
+			t.FailNow()
+		}
+		line := regexp.MustCompile(`^203\.0\.113\.7 - - \[\d{2}/\w{3}/\d{4}:\d{2}:\d{2}:\d{2} [+-]\d{4}\] "GET /api/orders/42\?expand=items HTTP/1\.1" 200 11 "https://example\.com/" "curl/8\.5\.0"$`)
+		if !line.MatchString(entries[0].Info) {
+			t.Errorf("expect combined log line, given %s", entries[0].Info)
+		}
+	})
+}
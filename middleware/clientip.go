@@ -0,0 +1,153 @@
+package middleware
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"strings"
+
+	"github.com/iTchTheRightSpot/utility/utils"
+)
+
+// defaultClientIPHeaders are consulted when Middleware.ClientIPHeaders is
+// empty. Single value headers such as X-Real-IP and CF-Connecting-IP are only
+// safe when the proxy in front always overwrites them, so they are opt-in.
+var defaultClientIPHeaders = []string{"Forwarded", "X-Forwarded-For"}
+
+// TrustedProxies parses CIDRs or single addresses into prefixes for
+// Middleware.TrustedProxies e.g. TrustedProxies("10.0.0.0/8", "::1").
+func TrustedProxies(cidrs ...string) ([]netip.Prefix, error) {
+	res := make([]netip.Prefix, 0, len(cidrs))
+	for _, c := range cidrs {
+		c = strings.TrimSpace(c)
+		if strings.Contains(c, "/") {
+			p, err := netip.ParsePrefix(c)
+			if err != nil {
+				return nil, err
+			}
+			res = append(res, p.Masked())
+			continue
+		}
+		addr, err := netip.ParseAddr(c)
+		if err != nil {
+			return nil, fmt.Errorf("trusted proxy %q: %w", c, err)
+		}
+		addr = addr.Unmap()
+		res = append(res, netip.PrefixFrom(addr, addr.BitLen()))
+	}
+	return res, nil
+}
+
+// ClientIP returns the address of the client that sent r. Inside Log it is
+// read from the utils.RequestBody in the request context, otherwise it is
+// resolved from r.
+func (dep *Middleware) ClientIP(r *http.Request) string {
+	if obj, ok := r.Context().Value(utils.RequestKey).(*utils.RequestBody); ok && obj != nil && obj.Ip != "" {
+		return obj.Ip
+	}
+	return dep.clientIP(r)
+}
+
+// clientIP only believes forwarding headers when the connection comes from a
+// trusted proxy. Chained headers are walked right to left skipping trusted
+// proxies, as every hop appends the address it received the request from and
+// only the part added by our own proxies cannot be forged by the client.
+func (dep *Middleware) clientIP(r *http.Request) string {
+	remote, ok := parseIP(r.RemoteAddr)
+	if !ok {
+		return r.RemoteAddr
+	}
+	if !dep.trusted(remote) {
+		return remote.String()
+	}
+
+	headers := dep.ClientIPHeaders
+	if len(headers) == 0 {
+		headers = defaultClientIPHeaders
+	}
+	for _, h := range headers {
+		var ip netip.Addr
+		switch http.CanonicalHeaderKey(h) {
+		case "Forwarded":
+			ip, ok = dep.walk(forwardedFor(r.Header.Values("Forwarded")))
+		case "X-Forwarded-For":
+			ip, ok = dep.walk(splitList(r.Header.Values("X-Forwarded-For")))
+		default:
+			ip, ok = parseIP(r.Header.Get(h))
+		}
+		if ok {
+			return ip.String()
+		}
+	}
+	return remote.String()
+}
+
+func (dep *Middleware) trusted(ip netip.Addr) bool {
+	for _, p := range dep.TrustedProxies {
+		if p.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// walk returns the right-most address that is not a trusted proxy, or the
+// left-most when every hop is trusted. An unparsable hop ends the walk as
+// anything to its left may have been written by the client.
+func (dep *Middleware) walk(hops []string) (netip.Addr, bool) {
+	var last netip.Addr
+	for i := len(hops) - 1; i >= 0; i-- {
+		ip, ok := parseIP(hops[i])
+		if !ok {
+			return netip.Addr{}, false
+		}
+		if !dep.trusted(ip) {
+			return ip, true
+		}
+		last = ip
+	}
+	return last, last.IsValid()
+}
+
+func splitList(values []string) []string {
+	var res []string
+	for _, v := range values {
+		for _, s := range strings.Split(v, ",") {
+			if s = strings.TrimSpace(s); s != "" {
+				res = append(res, s)
+			}
+		}
+	}
+	return res
+}
+
+// forwardedFor extracts the for= parameter of every RFC 7239 element e.g.
+// Forwarded: for=192.0.2.60;proto=http, for="[2001:db8::1]:4711"
+func forwardedFor(values []string) []string {
+	var res []string
+	for _, element := range splitList(values) {
+		for _, pair := range strings.Split(element, ";") {
+			k, v, ok := strings.Cut(strings.TrimSpace(pair), "=")
+			if ok && strings.EqualFold(k, "for") {
+				res = append(res, strings.Trim(v, `"`))
+			}
+		}
+	}
+	return res
+}
+
+// parseIP accepts an address with or without a port, IPv6 in brackets
+// included, and maps IPv4-mapped IPv6 addresses back to IPv4.
+func parseIP(s string) (netip.Addr, bool) {
+	s = strings.Trim(strings.TrimSpace(s), `"`)
+	if host, _, err := net.SplitHostPort(s); err == nil {
+		s = host
+	}
+	s = strings.TrimSuffix(strings.TrimPrefix(s, "["), "]")
+	ip, err := netip.ParseAddr(s)
+	if err != nil {
+		return netip.Addr{}, false
+	}
+	return ip.Unmap(), true
+}
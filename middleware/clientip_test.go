@@ -0,0 +1,138 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/iTchTheRightSpot/utility/utils"
+)
+
+func TestClientIP(t *testing.T) {
+	t.Parallel()
+
+	proxies, err := TrustedProxies("10.0.0.0/8", "2001:db8::1")
+	if err != nil {
+		t.Error(err.Error())
+		t.FailNow()
+	}
+
+	cases := []struct {
+		name    string
+		remote  string
+		headers map[string][]string
+		custom  []string
+		expect  string
+	}{
+		{
+			name:    "untrusted peer cannot spoof",
+			remote:  "203.0.113.9:5123",
+			headers: map[string][]string{"X-Forwarded-For": {"1.1.1.1"}},
+			expect:  "203.0.113.9",
+		},
+		{
+			name:    "right-most untrusted hop wins",
+			remote:  "10.0.0.2:443",
+			headers: map[string][]string{"X-Forwarded-For": {"1.1.1.1, 198.51.100.7", "10.0.0.5"}},
+			expect:  "198.51.100.7",
+		},
+		{
+			name:    "every hop trusted",
+			remote:  "10.0.0.2:443",
+			headers: map[string][]string{"X-Forwarded-For": {"10.0.0.9, 10.0.0.5"}},
+			expect:  "10.0.0.9",
+		},
+		{
+			name:    "unparsable hop falls back to peer",
+			remote:  "10.0.0.2:443",
+			headers: map[string][]string{"X-Forwarded-For": {"198.51.100.7, garbage"}},
+			expect:  "10.0.0.2",
+		},
+		{
+			name:    "forwarded with ipv6 and port",
+			remote:  "[2001:db8::1]:443",
+			headers: map[string][]string{"Forwarded": {`for="[2001:db8:cafe::17]:4711";proto=https, for=10.0.0.3`}},
+			expect:  "2001:db8:cafe::17",
+		},
+		{
+			name:    "forwarded takes precedence over x-forwarded-for",
+			remote:  "10.0.0.2:443",
+			headers: map[string][]string{"Forwarded": {"for=192.0.2.60:80"}, "X-Forwarded-For": {"198.51.100.7"}},
+			expect:  "192.0.2.60",
+		},
+		{
+			name:    "cloudflare header when configured",
+			remote:  "10.0.0.2:443",
+			headers: map[string][]string{"Cf-Connecting-Ip": {"192.0.2.44"}, "X-Forwarded-For": {"198.51.100.7"}},
+			custom:  []string{"CF-Connecting-IP", "X-Forwarded-For"},
+			expect:  "192.0.2.44",
+		},
+		{
+			name:    "x-real-ip ignored unless configured",
+			remote:  "10.0.0.2:443",
+			headers: map[string][]string{"X-Real-Ip": {"192.0.2.44"}},
+			expect:  "10.0.0.2",
+		},
+		{
+			name:   "ipv4-mapped peer",
+			remote: "[::ffff:203.0.113.9]:80",
+			expect: "203.0.113.9",
+		},
+	}
+
+	for _, c := range cases {
+		// given
+		m := &Middleware{TrustedProxies: proxies, ClientIPHeaders: c.custom}
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.RemoteAddr = c.remote
+		for k, v := range c.headers {
+			req.Header[k] = v
+		}
+
+		// method to test
+		ip := m.clientIP(req)
+
+		// assert
+		if ip != c.expect {
+			t.Errorf("%s: expect %s, given %s", c.name, c.expect, ip)
+		}
+	}
+
+	t.Run("resolved ip is shared through the request context", func(t *testing.T) {
+		t.Parallel()
+
+		// given
+		lg := utils.RecordingLogger()
+		m := &Middleware{Logger: lg, TrustedProxies: proxies}
+		var seen string
+		h := m.Log(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			seen = m.ClientIP(r)
+		}))
+		req := httptest.NewRequest(http.MethodGet, "/api", nil)
+		req.RemoteAddr = "10.0.0.2:443"
+		req.Header.Set("X-Forwarded-For", "198.51.100.7")
+
+		// method to test
+		h.ServeHTTP(httptest.NewRecorder(), req)
+
+		// assert
+		if seen != "198.51.100.7" {
+			t.Errorf("expect 198.51.100.7, given %s", seen)
+		}
+		lg.AssertLogged(t, utils.Query{Message: "request completed"})
+		if e := lg.Entries()[0]; e.Ip != "198.51.100.7" {
+			t.Errorf("expect logged ip 198.51.100.7, given %s", e.Ip)
+		}
+	})
+
+	t.Run("rejects invalid proxies", func(t *testing.T) {
+		t.Parallel()
+
+		if _, err := TrustedProxies("10.0.0.0/33"); err == nil {
+			t.Error("expect error for invalid prefix")
+		}
+		if _, err := TrustedProxies("proxy.local"); err == nil {
+			t.Error("expect error for hostname")
+		}
+	})
+}
@@ -0,0 +1,57 @@
+package middleware
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/iTchTheRightSpot/utility/utils"
+)
+
+// unmatchedRoute labels requests no ServeMux pattern matched, keeping scanners
+// and typos from creating a series per path.
+const unmatchedRoute = "unmatched"
+
+// Metrics records request counts, latency and in flight requests labeled by
+// method, route pattern and status class. Serve the registry with
+// utils.MetricsHandler. Route patterns are set by http.ServeMux so Metrics
+// must wrap the mux.
+func (dep *Middleware) Metrics(next http.Handler) http.Handler {
+	reg := dep.Registry
+	if reg == nil {
+		reg = utils.DefaultRegistry
+	}
+	requests := reg.Counter("http_requests_total", "HTTP requests served.", "method", "route", "status")
+	duration := reg.Histogram("http_request_duration_seconds", "HTTP request latency in seconds.", utils.DefBuckets, "method", "route", "status")
+	inFlight := reg.Gauge("http_requests_in_flight", "HTTP requests being served.", "method")
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		method := methodLabel(r.Method)
+		inFlight.Inc(method)
+		defer inFlight.Dec(method)
+
+		obj := &logWriter{ResponseWriter: w, code: http.StatusOK}
+		next.ServeHTTP(extend(obj), r)
+
+		route := r.Pattern
+		if route == "" {
+			route = unmatchedRoute
+		}
+		status := strconv.Itoa(obj.code/100) + "xx"
+		requests.Inc(method, route, status)
+		duration.Observe(time.Since(start).Seconds(), method, route, status)
+	})
+}
+
+// methodLabel folds non standard methods into OTHER so clients cannot grow
+// the number of series.
+func methodLabel(m string) string {
+	switch m {
+	case http.MethodGet, http.MethodHead, http.MethodPost, http.MethodPut, http.MethodPatch,
+		http.MethodDelete, http.MethodConnect, http.MethodOptions, http.MethodTrace:
+		return m
+	default:
+		return "OTHER"
+	}
+}
@@ -0,0 +1,73 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/iTchTheRightSpot/utility/utils"
+)
+
+func TestMetrics(t *testing.T) {
+	t.Parallel()
+
+	t.Run("should label requests by method route and status class", func(t *testing.T) {
+		t.Parallel()
+
+		// given
+		reg := utils.MetricsRegistry()
+		m := &Middleware{Logger: utils.RecordingLogger(), Registry: reg}
+		mux := http.NewServeMux()
+		mux.HandleFunc("GET /api/orders/{id}", func(w http.ResponseWriter, r *http.Request) {
+			if r.PathValue("id") == "0" {
+				w.WriteHeader(http.StatusNotFound)
+			}
+		})
+		h := m.Metrics(mux)
+
+		// method to test
+		for _, path := range []string{"/api/orders/1", "/api/orders/2", "/api/orders/0", "/wp-admin"} {
+			h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, path, nil))
+		}
+		h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("PURGE", "/api/orders/1", nil))
+
+		// assert
+		w := httptest.NewRecorder()
+		utils.MetricsHandler(reg).ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+		for _, line := range []string{
+			`http_requests_total{method="GET",route="GET /api/orders/{id}",status="2xx"} 2`,
+			`http_requests_total{method="GET",route="GET /api/orders/{id}",status="4xx"} 1`,
+			`http_requests_total{method="GET",route="unmatched",status="4xx"} 1`,
+			`http_requests_total{method="OTHER",route="unmatched",status="4xx"} 1`,
+			`http_request_duration_seconds_count{method="GET",route="GET /api/orders/{id}",status="2xx"} 2`,
+			`http_requests_in_flight{method="GET"} 0`,
+		} {
+			if !strings.Contains(w.Body.String(), line) {
+				t.Errorf("expect %s, given\n%s", line, w.Body.String())
+			}
+		}
+	})
+
+	t.Run("should label routes when wrapping other middleware", func(t *testing.T) {
+		t.Parallel()
+
+		// given
+		reg := utils.MetricsRegistry()
+		m := &Middleware{Logger: utils.RecordingLogger(), Registry: reg}
+		mux := http.NewServeMux()
+		mux.HandleFunc("GET /x/{id}", func(w http.ResponseWriter, r *http.Request) {})
+		h := m.Metrics(m.Log(m.Error(mux)))
+
+		// method to test
+		h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/x/1", nil))
+
+		// assert
+		w := httptest.NewRecorder()
+		utils.MetricsHandler(reg).ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+		line := `http_requests_total{method="GET",route="GET /x/{id}",status="2xx"} 1`
+		if !strings.Contains(w.Body.String(), line) {
+			t.Errorf("expect %s, given\n%s", line, w.Body.String())
+		}
+	})
+}
@@ -2,10 +2,9 @@ package middleware
 
 import (
 	"context"
-	"fmt"
-	"github.com/google/uuid"
 	"github.com/iTchTheRightSpot/utility/utils"
 	"net/http"
+	"net/netip"
 	"runtime"
 	"strings"
 )
@@ -14,33 +13,87 @@ type Middleware struct {
 	Logger    utils.ILogger
 	Fs        http.FileSystem
 	ApiPrefix string
+	// TrustedProxies are the proxies allowed to report the client address.
+	// Forwarding headers are ignored unless the connection comes from one.
+	TrustedProxies []netip.Prefix
+	// ClientIPHeaders are consulted in order once the connection is trusted.
+	// Defaults to Forwarded and X-Forwarded-For, add X-Real-IP or
+	// CF-Connecting-IP when the proxy in front sets them.
+	ClientIPHeaders []string
+	// RequestIDHeaders are read in order for an id set by a load balancer or
+	// upstream service. The first is echoed in the response. Defaults to
+	// X-Request-ID.
+	RequestIDHeaders []string
+	// RequestID generates ids for requests without a valid inbound one e.g.
+	// UUIDv7 or ULID for time-sortable ids. Defaults to UUIDv4.
+	RequestID func() string
+	// Tracer exports a server span per request. Without one trace ids are
+	// still propagated and logged but no span is exported.
+	Tracer utils.ITracer
+	// Registry receives the request metrics of Metrics. Defaults to
+	// utils.DefaultRegistry.
+	Registry *utils.Registry
+	// AccessLogFormat selects structured access entries, the default, or
+	// AccessLogCombined for Apache Combined Log Format lines.
+	AccessLogFormat AccessLogFormat
+	// Errors opts error responses into RFC 9457 problem details and HTML
+	// pages for browsers. nil keeps {"message": ...} bodies.
+	Errors *utils.ErrorConfig
 }
 
-// https://stackoverflow.com/questions/27234861/correct-way-of-getting-clients-ip-addresses-from-http-request
-func (dep *Middleware) clientIP(r *http.Request) string {
-	ip := r.Header.Get("X-Forwarded-For")
-	if ip != "" {
-		return strings.Split(ip, ",")[0]
-	}
-	return r.RemoteAddr
-}
+// noopTracer generates ids without exporting spans.
+var noopTracer = utils.Tracer("", nil)
 
 func (dep *Middleware) Log(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		start := dep.Logger.Date()
+		ctx, span := dep.span(r)
+		sc := span.SpanContext()
 		b := &utils.RequestBody{
-			Id:     uuid.NewString(),
-			Ip:     dep.clientIP(r),
-			Method: r.Method,
-			Path:   r.URL.Path,
+			Id:      dep.requestID(r),
+			Ip:      dep.clientIP(r),
+			Method:  r.Method,
+			Path:    r.URL.Path,
+			TraceId: sc.TraceID.String(),
+			SpanId:  sc.SpanID.String(),
 		}
-		r = r.WithContext(context.WithValue(r.Context(), utils.RequestKey, b))
+		in := r
+		r = r.WithContext(context.WithValue(ctx, utils.RequestKey, b))
+		w.Header().Set(dep.requestIDHeader(), b.Id)
 		obj := &logWriter{ResponseWriter: w, code: http.StatusOK}
-		next.ServeHTTP(obj, r)
-		dep.Logger.Log(r.Context(), fmt.Sprintf("Response Status: %d | Duration: %v second(s)", obj.code, dep.Logger.Date().Sub(start).Seconds()))
+		next.ServeHTTP(extend(obj), r)
+		// ServeMux sets the pattern on the clone, an outer Metrics reads in
+		in.Pattern = r.Pattern
+
+		// the matched pattern keeps span names low cardinality unlike the path
+		if r.Pattern != "" {
+			span.SetName(r.Pattern)
+		}
+		span.SetAttributes(utils.String("http.request.method", r.Method), utils.String("url.path", r.URL.Path), utils.Int("http.response.status_code", obj.code))
+		if obj.code >= http.StatusInternalServerError {
+			span.SetStatus(utils.StatusError, http.StatusText(obj.code))
+		}
+		span.End()
+		dep.access(r, obj, start)
 	})
 }
 
+// span starts the server span of r, continuing the trace of a valid
+// traceparent header or starting a new one.
+func (dep *Middleware) span(r *http.Request) (context.Context, *utils.Span) {
+	t := dep.Tracer
+	if t == nil {
+		t = noopTracer
+	}
+	ctx := r.Context()
+	if sc, ok := utils.Extract(r.Header); ok {
+		ctx = utils.ContextWithRemoteSpanContext(ctx, sc)
+	}
+	ctx, span := t.Start(ctx, r.Method)
+	span.SetKind(utils.SpanKindServer)
+	return ctx, span
+}
+
 // SPA loads single page or frontend pages registered in FileSystem
 func (dep *Middleware) SPA(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -92,16 +145,33 @@ func (dep *Middleware) Panic(next http.Handler) http.Handler {
 				buf := make([]byte, 2048)
 				n := runtime.Stack(buf, true)
 				buf = buf[:n]
-				dep.Logger.Critical(r.Context(), err, string(buf))
-				utils.ErrorResponse(w, &utils.ServerError{})
+				dep.Logger.Critical(r.Context(), "panic recovered", utils.Any("panic", err), utils.String("stack", string(buf)))
+				dep.writeError(w, r, &utils.ServerError{})
 			}
 		}()
 		next.ServeHTTP(w, r)
 	})
 }
 
+// Error replaces the plain text errors of http.ServeMux and http.TimeoutHandler
+// with JSON and hands Errors to utils.WriteError in handlers.
 func (dep *Middleware) Error(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		next.ServeHTTP(&errorWriter{ResponseWriter: w}, r)
+		r2 := dep.withErrors(r)
+		next.ServeHTTP(extend(&errorWriter{ResponseWriter: w, r: r2, problems: dep.Errors != nil}), r2)
+		// ServeMux sets the pattern on the clone, outer Log and Metrics read r
+		r.Pattern = r2.Pattern
 	})
+}
+
+// withErrors adds Errors to the context of r for utils.WriteError.
+func (dep *Middleware) withErrors(r *http.Request) *http.Request {
+	if dep.Errors == nil {
+		return r
+	}
+	return r.WithContext(utils.ContextWithErrorConfig(r.Context(), dep.Errors))
+}
+
+func (dep *Middleware) writeError(w http.ResponseWriter, r *http.Request, err error) {
+	utils.WriteError(w, dep.withErrors(r), err)
 }
\ No newline at end of file
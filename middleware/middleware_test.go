@@ -215,7 +215,8 @@ func TestMiddleware(t *testing.T) {
 		t.Parallel()
 
 		// given
-		m := Middleware{Logger: lg}
+		rec := utils.RecordingLogger()
+		m := Middleware{Logger: rec}
 		mux := http.NewServeMux()
 		mux.HandleFunc("GET /", func(w http.ResponseWriter, r *http.Request) {
 			panic("simulate error")
@@ -239,5 +240,7 @@ func TestMiddleware(t *testing.T) {
 			t.Errorf("expect equal, expect %s, got %s", s, str)
 			t.FailNow()
 		}
+
+		rec.AssertLogged(t, utils.Query{Level: "CRITICAL", Message: "panic recovered", Field: "stack"})
 	})
 }
\ No newline at end of file
@@ -1,12 +1,82 @@
 package middleware
 
 import (
+	"io"
 	"net/http"
+
+	"github.com/iTchTheRightSpot/utility/utils"
 )
 
+// responseWriter is a middleware writer that extend can dress with the
+// optional interfaces of the writer it wraps.
+type responseWriter interface {
+	http.ResponseWriter
+	// Unwrap lets http.ResponseController reach the wrapped writer.
+	Unwrap() http.ResponseWriter
+	readFrom(src io.Reader) (int64, error)
+}
+
+type readerFrom struct{ responseWriter }
+
+func (w readerFrom) ReadFrom(src io.Reader) (int64, error) {
+	return w.readFrom(src)
+}
+
+// extend returns rw implementing exactly the http.Flusher, http.Hijacker and
+// io.ReaderFrom interfaces its wrapped writer implements, so SSE, WebSocket
+// upgrades and sendfile keep working behind the middleware.
+func extend(rw responseWriter) http.ResponseWriter {
+	f, isFlusher := rw.Unwrap().(http.Flusher)
+	h, isHijacker := rw.Unwrap().(http.Hijacker)
+	_, isReaderFrom := rw.Unwrap().(io.ReaderFrom)
+
+	switch {
+	case isFlusher && isHijacker && isReaderFrom:
+		return struct {
+			readerFrom
+			http.Flusher
+			http.Hijacker
+		}{readerFrom{rw}, f, h}
+	case isFlusher && isHijacker:
+		return struct {
+			responseWriter
+			http.Flusher
+			http.Hijacker
+		}{rw, f, h}
+	case isFlusher && isReaderFrom:
+		return struct {
+			readerFrom
+			http.Flusher
+		}{readerFrom{rw}, f}
+	case isHijacker && isReaderFrom:
+		return struct {
+			readerFrom
+			http.Hijacker
+		}{readerFrom{rw}, h}
+	case isFlusher:
+		return struct {
+			responseWriter
+			http.Flusher
+		}{rw, f}
+	case isHijacker:
+		return struct {
+			responseWriter
+			http.Hijacker
+		}{rw, h}
+	case isReaderFrom:
+		return readerFrom{rw}
+	default:
+		return rw
+	}
+}
+
+// writerOnly hides the ReadFrom of w so io.Copy goes through Write.
+type writerOnly struct{ io.Writer }
+
 type logWriter struct {
 	http.ResponseWriter
-	code int
+	code  int
+	bytes int64
 }
 
 func (w *logWriter) WriteHeader(code int) {
@@ -14,10 +84,45 @@ func (w *logWriter) WriteHeader(code int) {
 	w.ResponseWriter.WriteHeader(code)
 }
 
+func (w *logWriter) Write(body []byte) (int, error) {
+	n, err := w.ResponseWriter.Write(body)
+	w.bytes += int64(n)
+	return n, err
+}
+
+func (w *logWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// readFrom is only called when the wrapped writer is an io.ReaderFrom.
+func (w *logWriter) readFrom(src io.Reader) (int64, error) {
+	n, err := w.ResponseWriter.(io.ReaderFrom).ReadFrom(src)
+	w.bytes += n
+	return n, err
+}
+
 type errorWriter struct {
 	http.ResponseWriter
+	r        *http.Request
 	code     int
 	override bool
+	// problems writes overridden errors with utils.WriteError, dropping the
+	// plain text body that follows.
+	problems bool
+	replaced bool
+}
+
+// overrideMessage is the message replacing plain text errors of code.
+func overrideMessage(code int) string {
+	switch code {
+	case http.StatusNotFound:
+		return "route not found"
+	case http.StatusMethodNotAllowed:
+		return "method not allowed"
+	case http.StatusServiceUnavailable:
+		return "request timeout"
+	}
+	return ""
 }
 
 func (w *errorWriter) WriteHeader(code int) {
@@ -25,6 +130,13 @@ func (w *errorWriter) WriteHeader(code int) {
 	// update this behaviour https://github.com/golang/go/issues/65648
 	t := w.Header().Get("Content-Type")
 	if t == "text/plain; charset=utf-8" || (t == "" && code == http.StatusServiceUnavailable) {
+		if msg := overrideMessage(code); w.problems && msg != "" {
+			w.replaced = true
+			w.Header().Del("Content-Length")
+			w.Header().Del("X-Content-Type-Options")
+			utils.WriteError(w.ResponseWriter, w.r, &utils.Problem{Status: code, Detail: msg})
+			return
+		}
 		w.Header().Set("Content-Type", "application/json")
 		w.override = true
 	}
@@ -33,15 +145,25 @@ func (w *errorWriter) WriteHeader(code int) {
 }
 
 func (w *errorWriter) Write(body []byte) (int, error) {
+	if w.replaced {
+		return len(body), nil
+	}
 	if w.override {
-		switch w.code {
-		case http.StatusNotFound:
-			body = []byte(`{"message":"route not found"}`)
-		case http.StatusMethodNotAllowed:
-			body = []byte(`{"message":"method not allowed"}`)
-		case http.StatusServiceUnavailable:
-			body = []byte(`{"message":"request timeout"}`)
+		if msg := overrideMessage(w.code); msg != "" {
+			body = []byte(`{"message":"` + msg + `"}`)
 		}
 	}
 	return w.ResponseWriter.Write(body)
+}
+
+func (w *errorWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// readFrom copies through Write when the body is being replaced.
+func (w *errorWriter) readFrom(src io.Reader) (int64, error) {
+	if w.override || w.replaced {
+		return io.Copy(writerOnly{w}, src)
+	}
+	return w.ResponseWriter.(io.ReaderFrom).ReadFrom(src)
 }
\ No newline at end of file
@@ -0,0 +1,76 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/iTchTheRightSpot/utility/utils"
+)
+
+func TestProblemDetails(t *testing.T) {
+	t.Parallel()
+
+	m := &Middleware{Logger: utils.RecordingLogger(), Errors: &utils.ErrorConfig{Format: utils.ProblemFormat, HTML: utils.DefaultErrorPage}}
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /api/orders", func(w http.ResponseWriter, r *http.Request) {
+		utils.WriteError(w, r, &utils.AccessDeniedError{})
+	})
+	h := m.Log(m.Error(mux))
+
+	cases := []struct {
+		method, path, accept string
+		code                 int
+		contentType, body    string
+	}{
+		{http.MethodGet, "/api/missing", "application/json", http.StatusNotFound, "application/problem+json", `"detail":"route not found"`},
+		{http.MethodPost, "/api/orders", "", http.StatusMethodNotAllowed, "application/problem+json", `"detail":"method not allowed"`},
+		{http.MethodGet, "/api/orders", "", http.StatusForbidden, "application/problem+json", `"instance":"/api/orders"`},
+		{http.MethodGet, "/dashboard", "text/html", http.StatusNotFound, "text/html; charset=utf-8", "<h1>404 Not Found</h1>"},
+	}
+
+	for _, c := range cases {
+		// given
+		req := httptest.NewRequest(c.method, c.path, nil)
+		if c.accept != "" {
+			req.Header.Set("Accept", c.accept)
+		}
+		w := httptest.NewRecorder()
+
+		// method to test
+		h.ServeHTTP(w, req)
+
+		// assert
+		if w.Code != c.code || w.Header().Get("Content-Type") != c.contentType {
+			t.Errorf("%s %s: expect %d %s, given %d %s", c.method, c.path, c.code, c.contentType, w.Code, w.Header().Get("Content-Type"))
+		}
+		if !strings.Contains(w.Body.String(), c.body) || strings.Contains(w.Body.String(), "404 page not found") {
+			t.Errorf("%s %s: expect %s, given %s", c.method, c.path, c.body, w.Body.String())
+		}
+		if !strings.Contains(w.Body.String(), w.Header().Get("X-Request-ID")) {
+			t.Errorf("expect request id in body, given %s", w.Body.String())
+		}
+	}
+
+	t.Run("should keep the route for outer middleware", func(t *testing.T) {
+		t.Parallel()
+
+		// given
+		lg := utils.RecordingLogger()
+		m := &Middleware{Logger: lg, Registry: utils.MetricsRegistry(), Errors: &utils.ErrorConfig{Format: utils.ProblemFormat}}
+		mux := http.NewServeMux()
+		mux.HandleFunc("GET /api/orders/{id}", func(w http.ResponseWriter, r *http.Request) {})
+
+		// method to test
+		m.Log(m.Error(mux)).ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/api/orders/42", nil))
+
+		// assert
+		lg.AssertCount(t, utils.Query{Message: "request completed", Field: "route"}, 1)
+		for _, f := range lg.Find(utils.Query{Message: "request completed"})[0].Fields {
+			if f.Key == "route" && f.Value != "GET /api/orders/{id}" {
+				t.Errorf("expect matched pattern, given %v", f.Value)
+			}
+		}
+	})
+}
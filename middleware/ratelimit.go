@@ -0,0 +1,206 @@
+package middleware
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/iTchTheRightSpot/utility/cache"
+	"github.com/iTchTheRightSpot/utility/utils"
+)
+
+type RateLimitAlgorithm int
+
+const (
+	// TokenBucket allows bursts of up to Limit requests and refills Limit
+	// tokens evenly over every Window.
+	TokenBucket RateLimitAlgorithm = iota
+	// SlidingWindow allows Limit requests in any Window, weighting the
+	// previous window by how much of it still overlaps.
+	SlidingWindow
+)
+
+// RateLimitState is what a limiter keeps per key in the cache.
+type RateLimitState struct {
+	// Tokens and Last are used by TokenBucket.
+	Tokens float64
+	Last   time.Time
+	// WindowStart, Previous and Current are used by SlidingWindow.
+	WindowStart time.Time
+	Previous    int
+	Current     int
+}
+
+type RateLimitConfig struct {
+	Algorithm RateLimitAlgorithm
+	// Limit is the number of requests allowed per Window.
+	Limit  int
+	Window time.Duration
+	// Key identifies the client, see RateLimitByHeader and RateLimitByContext.
+	// Defaults to the client IP, which is also used when Key returns "".
+	Key func(r *http.Request) string
+	// Store holds the state of every key. Its expiry should be at least
+	// Window. Updates are serialised within the process only, so a shared
+	// store lets concurrent instances slightly exceed Limit.
+	Store cache.ICache[string, RateLimitState]
+}
+
+// RateLimitByHeader keys clients by a header such as X-API-Key. The value is
+// hashed so secrets are never kept in the store.
+func RateLimitByHeader(name string) func(r *http.Request) string {
+	return func(r *http.Request) string {
+		v := r.Header.Get(name)
+		if v == "" {
+			return ""
+		}
+		sum := sha256.Sum256([]byte(v))
+		return name + ":" + hex.EncodeToString(sum[:])
+	}
+}
+
+// RateLimitByContext keys clients by a request context value, such as the
+// authenticated user set by an authentication middleware.
+func RateLimitByContext(key interface{}) func(r *http.Request) string {
+	return func(r *http.Request) string {
+		v := r.Context().Value(key)
+		if v == nil {
+			return ""
+		}
+		return fmt.Sprintf("%v", v)
+	}
+}
+
+type limiter struct {
+	cfg RateLimitConfig
+	mu  sync.Mutex
+	now func() time.Time
+}
+
+type decision struct {
+	allowed   bool
+	remaining int
+	// reset is when the quota is fully available again and retry when the
+	// next request is allowed.
+	reset time.Duration
+	retry time.Duration
+}
+
+// RateLimit rejects clients exceeding cfg with a JSON 429. Every response
+// carries RateLimit-Limit, RateLimit-Remaining and RateLimit-Reset headers and
+// rejections also carry Retry-After.
+func (dep *Middleware) RateLimit(cfg RateLimitConfig) func(http.Handler) http.Handler {
+	if cfg.Limit <= 0 || cfg.Window <= 0 || cfg.Store == nil {
+		panic("rate limit: limit, window and store are required")
+	}
+	l := &limiter{cfg: cfg, now: time.Now}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			key := ""
+			if cfg.Key != nil {
+				key = cfg.Key(r)
+			}
+			if key == "" {
+				key = "ip:" + dep.ClientIP(r)
+			}
+
+			d := l.take(key)
+			h := w.Header()
+			h.Set("RateLimit-Limit", strconv.Itoa(cfg.Limit))
+			h.Set("RateLimit-Remaining", strconv.Itoa(d.remaining))
+			h.Set("RateLimit-Reset", strconv.Itoa(seconds(d.reset)))
+			h.Set("RateLimit-Policy", fmt.Sprintf("%d;w=%d", cfg.Limit, seconds(cfg.Window)))
+
+			if !d.allowed {
+				dep.Logger.Log(r.Context(), "rate limit exceeded", utils.Int("limit", cfg.Limit), utils.Duration("retry_after", d.retry))
+				dep.writeError(w, r, &utils.TooManyRequestsError{RetryAfter: d.retry})
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// seconds rounds d up so clients never retry too early.
+func seconds(d time.Duration) int {
+	return int(math.Ceil(d.Seconds()))
+}
+
+func (l *limiter) take(key string) decision {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	var s RateLimitState
+	if v := l.cfg.Store.Get(key); v != nil {
+		s = *v
+	}
+
+	var d decision
+	if l.cfg.Algorithm == SlidingWindow {
+		d = l.slidingWindow(&s, l.now())
+	} else {
+		d = l.tokenBucket(&s, l.now())
+	}
+	l.cfg.Store.Put(key, s)
+	return d
+}
+
+func (l *limiter) tokenBucket(s *RateLimitState, now time.Time) decision {
+	limit := float64(l.cfg.Limit)
+	// tokens refilled per second
+	rate := limit / l.cfg.Window.Seconds()
+
+	if s.Last.IsZero() {
+		s.Tokens = limit
+	} else {
+		s.Tokens = math.Min(limit, s.Tokens+now.Sub(s.Last).Seconds()*rate)
+	}
+	s.Last = now
+
+	d := decision{allowed: s.Tokens >= 1}
+	if d.allowed {
+		s.Tokens--
+	} else {
+		d.retry = time.Duration((1 - s.Tokens) / rate * float64(time.Second))
+	}
+	d.remaining = int(s.Tokens)
+	d.reset = time.Duration((limit - s.Tokens) / rate * float64(time.Second))
+	return d
+}
+
+func (l *limiter) slidingWindow(s *RateLimitState, now time.Time) decision {
+	window := l.cfg.Window
+	start := now.Truncate(window)
+	switch {
+	case s.WindowStart.Equal(start):
+	case s.WindowStart.Add(window).Equal(start):
+		s.Previous, s.Current = s.Current, 0
+		s.WindowStart = start
+	default:
+		s.Previous, s.Current = 0, 0
+		s.WindowStart = start
+	}
+
+	elapsed := now.Sub(start)
+	weight := 1 - float64(elapsed)/float64(window)
+	estimate := float64(s.Previous)*weight + float64(s.Current)
+	limit := float64(l.cfg.Limit)
+
+	d := decision{allowed: estimate+1 <= limit, reset: window - elapsed}
+	if d.allowed {
+		s.Current++
+		estimate++
+	} else if s.Previous > 0 && float64(s.Current)+1 <= limit {
+		// the estimate drops by Previous every window as the previous window slides out
+		d.retry = time.Duration((estimate + 1 - limit) / float64(s.Previous) * float64(window))
+	} else {
+		d.retry = window - elapsed
+	}
+	d.remaining = int(math.Max(0, math.Floor(limit-estimate)))
+	return d
+}
@@ -0,0 +1,134 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/iTchTheRightSpot/utility/cache"
+	"github.com/iTchTheRightSpot/utility/utils"
+)
+
+func TestRateLimit(t *testing.T) {
+	t.Parallel()
+
+	ok := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	})
+
+	request := func(h http.Handler, remote, apiKey string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/api", nil)
+		req.RemoteAddr = remote
+		if apiKey != "" {
+			req.Header.Set("X-API-Key", apiKey)
+		}
+		rr := httptest.NewRecorder()
+		h.ServeHTTP(rr, req)
+		return rr
+	}
+
+	t.Run("token bucket per client ip", func(t *testing.T) {
+		t.Parallel()
+
+		// given
+		lg := utils.RecordingLogger()
+		m := &Middleware{Logger: lg}
+		h := m.RateLimit(RateLimitConfig{
+			Limit:  2,
+			Window: time.Minute,
+			Store:  cache.SyncMapInMemoryCache[string, RateLimitState](lg, 1, 100),
+		})(ok)
+
+		// method to test
+		first := request(h, "203.0.113.9:1000", "")
+		request(h, "203.0.113.9:1001", "")
+		third := request(h, "203.0.113.9:1002", "")
+		other := request(h, "198.51.100.7:1000", "")
+
+		// assert
+		if first.Code != http.StatusNoContent || first.Header().Get("RateLimit-Remaining") != "1" || first.Header().Get("RateLimit-Limit") != "2" {
+			t.Errorf("expect allowed with 1 remaining, given %d %v", first.Code, first.Header())
+		}
+		if third.Code != http.StatusTooManyRequests {
+			t.Errorf("expect %d, given %d", http.StatusTooManyRequests, third.Code)
+			t.FailNow()
+		}
+		if third.Header().Get("Retry-After") != "30" || third.Header().Get("RateLimit-Remaining") != "0" {
+			t.Errorf("expect retry after 30 seconds, given %v", third.Header())
+		}
+		if third.Header().Get("Content-Type") != "application/json" || !strings.Contains(third.Body.String(), `"too many requests"`) {
+			t.Errorf("expect json error body, given %s", third.Body.String())
+		}
+		if other.Code != http.StatusNoContent {
+			t.Errorf("expect other client to be allowed, given %d", other.Code)
+		}
+		lg.AssertCount(t, utils.Query{Message: "rate limit exceeded"}, 1)
+	})
+
+	t.Run("api key shared across addresses", func(t *testing.T) {
+		t.Parallel()
+
+		// given
+		lg := utils.RecordingLogger()
+		m := &Middleware{Logger: lg}
+		h := m.RateLimit(RateLimitConfig{
+			Limit:  1,
+			Window: time.Minute,
+			Key:    RateLimitByHeader("X-API-Key"),
+			Store:  cache.SyncMapInMemoryCache[string, RateLimitState](lg, 1, 100),
+		})(ok)
+
+		// method to test
+		a := request(h, "203.0.113.9:1000", "key-a")
+		b := request(h, "198.51.100.7:1000", "key-a")
+		c := request(h, "198.51.100.7:1000", "key-b")
+
+		// assert
+		if a.Code != http.StatusNoContent || b.Code != http.StatusTooManyRequests || c.Code != http.StatusNoContent {
+			t.Errorf("expect limit per api key, given %d %d %d", a.Code, b.Code, c.Code)
+		}
+	})
+
+	t.Run("sliding window weights the previous window", func(t *testing.T) {
+		t.Parallel()
+
+		// given
+		lg := utils.DevLogger("UTC")
+		now := time.Date(2026, 10, 19, 12, 0, 0, 0, time.UTC)
+		l := &limiter{
+			cfg: RateLimitConfig{
+				Algorithm: SlidingWindow,
+				Limit:     4,
+				Window:    10 * time.Second,
+				Store:     cache.SyncMapInMemoryCache[string, RateLimitState](lg, 1, 100),
+			},
+			now: func() time.Time { return now },
+		}
+
+		// method to test
+		for i := 0; i < 4; i++ {
+			if d := l.take("k"); !d.allowed {
+				t.Errorf("expect request %d to be allowed", i)
+			}
+		}
+		full := l.take("k")
+
+		// a quarter into the next window three requests of the previous still count
+		now = now.Add(12500 * time.Millisecond)
+		next := l.take("k")
+		denied := l.take("k")
+
+		// assert
+		if full.allowed || full.retry != 10*time.Second {
+			t.Errorf("expect denial until the window ends, given %+v", full)
+		}
+		if !next.allowed || next.remaining != 0 {
+			t.Errorf("expect one request allowed, given %+v", next)
+		}
+		if denied.allowed || denied.retry != 2500*time.Millisecond {
+			t.Errorf("expect retry once another request slid out, given %+v", denied)
+		}
+	})
+}
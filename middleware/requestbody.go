@@ -18,7 +18,7 @@ func (dep *RequestBodyMiddleware[T]) RequestBody(next http.Handler) http.Handler
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		if r.Body == nil {
 			dep.Logger.Error(r.Context(), "request body is nil")
-			utils.ErrorResponse(w, &utils.BadRequestError{Message: "invalid request body"})
+			utils.WriteError(w, r, &utils.BadRequestError{Message: "invalid request body"})
 			return
 		}
 
@@ -26,20 +26,20 @@ func (dep *RequestBodyMiddleware[T]) RequestBody(next http.Handler) http.Handler
 
 		if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
 			dep.Logger.Error(r.Context(), err.Error())
-			utils.ErrorResponse(w, &utils.BadRequestError{Message: "invalid request body"})
+			utils.WriteError(w, r, &utils.BadRequestError{Message: "invalid request body"})
 			return
 		}
 
 		if err := dep.Validator.Struct(payload); err != nil {
 			dep.Logger.Error(r.Context(), err.Error())
-			utils.ErrorResponse(w, &utils.BadRequestError{Message: "invalid request body"})
+			utils.WriteError(w, r, &utils.BadRequestError{Message: "invalid request body"})
 			return
 		}
 
 		by, err := json.Marshal(payload)
 		if err != nil {
 			dep.Logger.Error(r.Context(), err.Error())
-			utils.ErrorResponse(w, &utils.ServerError{})
+			utils.WriteError(w, r, &utils.ServerError{})
 			return
 		}
 		r.Body = io.NopCloser(bytes.NewBuffer(by))
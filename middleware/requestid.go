@@ -0,0 +1,91 @@
+package middleware
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// defaultRequestIDHeader is read and echoed when Middleware.RequestIDHeaders is empty.
+const defaultRequestIDHeader = "X-Request-ID"
+
+// maxRequestIDLength bounds inbound ids so clients cannot bloat every log entry.
+const maxRequestIDLength = 128
+
+// UUIDv4 generates a random UUID, the default request id.
+func UUIDv4() string {
+	return uuid.NewString()
+}
+
+// UUIDv7 generates a UUID that sorts by creation time (RFC 9562).
+func UUIDv7() string {
+	id, err := uuid.NewV7()
+	if err != nil {
+		return uuid.NewString()
+	}
+	return id.String()
+}
+
+// crockford is the ULID alphabet, base32 without I, L, O and U.
+const crockford = "0123456789ABCDEFGHJKMNPQRSTVWXYZ"
+
+// ULID generates a 26 character id made of a millisecond timestamp followed
+// by 80 random bits, so ids sort by creation time.
+func ULID() string {
+	var b [16]byte
+	binary.BigEndian.PutUint64(b[:8], uint64(time.Now().UnixMilli())<<16)
+	_, _ = rand.Read(b[6:])
+
+	// 128 bits encode to 26 characters of 5 bits, the first holding 3 bits
+	hi := binary.BigEndian.Uint64(b[:8])
+	lo := binary.BigEndian.Uint64(b[8:])
+	var out [26]byte
+	for i := 25; i >= 0; i-- {
+		out[i] = crockford[lo&31]
+		lo = lo>>5 | hi<<59
+		hi >>= 5
+	}
+	return string(out[:])
+}
+
+// validRequestID accepts printable ASCII without spaces, which keeps inbound
+// ids from breaking log lines or response headers.
+func validRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLength {
+		return false
+	}
+	for i := 0; i < len(id); i++ {
+		if id[i] <= ' ' || id[i] > '~' {
+			return false
+		}
+	}
+	return true
+}
+
+// requestID returns the first valid id found in RequestIDHeaders or a new one.
+func (dep *Middleware) requestID(r *http.Request) string {
+	headers := dep.RequestIDHeaders
+	if len(headers) == 0 {
+		headers = []string{defaultRequestIDHeader}
+	}
+	for _, h := range headers {
+		if id := r.Header.Get(h); validRequestID(id) {
+			return id
+		}
+	}
+	if dep.RequestID != nil {
+		return dep.RequestID()
+	}
+	return UUIDv4()
+}
+
+// requestIDHeader is the response header the id is echoed in.
+func (dep *Middleware) requestIDHeader() string {
+	if len(dep.RequestIDHeaders) == 0 {
+		return defaultRequestIDHeader
+	}
+	return dep.RequestIDHeaders[0]
+}
@@ -0,0 +1,117 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/iTchTheRightSpot/utility/utils"
+)
+
+func TestRequestID(t *testing.T) {
+	t.Parallel()
+
+	serve := func(m *Middleware, headers map[string]string) (string, *httptest.ResponseRecorder) {
+		var seen string
+		h := m.Log(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			seen = r.Context().Value(utils.RequestKey).(*utils.RequestBody).Id
+		}))
+		req := httptest.NewRequest(http.MethodGet, "/api", nil)
+		for k, v := range headers {
+			req.Header.Set(k, v)
+		}
+		rr := httptest.NewRecorder()
+		h.ServeHTTP(rr, req)
+		return seen, rr
+	}
+
+	t.Run("should propagate and echo inbound id", func(t *testing.T) {
+		t.Parallel()
+
+		// given
+		lg := utils.RecordingLogger()
+		m := &Middleware{Logger: lg}
+
+		// method to test
+		id, rr := serve(m, map[string]string{"X-Request-ID": "lb-7f3a"})
+
+		// assert
+		if id != "lb-7f3a" || rr.Header().Get("X-Request-ID") != "lb-7f3a" {
+			t.Errorf("expect lb-7f3a in context and response, given %s %s", id, rr.Header().Get("X-Request-ID"))
+		}
+		lg.AssertLogged(t, utils.Query{Message: "request completed", RequestId: "lb-7f3a"})
+	})
+
+	t.Run("should replace invalid inbound id", func(t *testing.T) {
+		t.Parallel()
+
+		// given
+		m := &Middleware{Logger: utils.RecordingLogger()}
+
+		for _, inbound := range []string{"has space", "line\nbreak", strings.Repeat("a", 129)} {
+			// method to test
+			id, rr := serve(m, map[string]string{"X-Request-ID": inbound})
+
+			// assert
+			if _, err := uuid.Parse(id); err != nil || rr.Header().Get("X-Request-ID") != id {
+				t.Errorf("expect generated id for %q, given %s", inbound, id)
+			}
+		}
+	})
+
+	t.Run("should read configured headers in order", func(t *testing.T) {
+		t.Parallel()
+
+		// given
+		m := &Middleware{Logger: utils.RecordingLogger(), RequestIDHeaders: []string{"X-Correlation-ID", "X-Amzn-Trace-Id"}}
+
+		// method to test
+		id, rr := serve(m, map[string]string{"X-Amzn-Trace-Id": "Root=1-67891233-abcdef012345678912345678"})
+
+		// assert
+		if id != "Root=1-67891233-abcdef012345678912345678" {
+			t.Errorf("expect amazon trace id, given %s", id)
+		}
+		if rr.Header().Get("X-Correlation-ID") != id {
+			t.Errorf("expect id echoed in the first header, given %v", rr.Header())
+		}
+	})
+
+	t.Run("should use configured generator", func(t *testing.T) {
+		t.Parallel()
+
+		// given
+		m := &Middleware{Logger: utils.RecordingLogger(), RequestID: UUIDv7}
+
+		// method to test
+		id, _ := serve(m, nil)
+
+		// assert
+		if u, err := uuid.Parse(id); err != nil || u.Version() != 7 {
+			t.Errorf("expect uuid v7, given %s", id)
+		}
+	})
+
+	t.Run("ulid sorts by time", func(t *testing.T) {
+		t.Parallel()
+
+		// method to test
+		a := ULID()
+		time.Sleep(2 * time.Millisecond)
+		b := ULID()
+
+		// assert
+		if len(a) != 26 || strings.Trim(a, crockford) != "" {
+			t.Errorf("expect 26 crockford characters, given %s", a)
+		}
+		if a >= b {
+			t.Errorf("expect %s to sort before %s", a, b)
+		}
+		if a[0] > '7' {
+			t.Errorf("expect first character to hold 3 bits, given %s", a)
+		}
+	})
+}
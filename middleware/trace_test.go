@@ -0,0 +1,95 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/iTchTheRightSpot/utility/utils"
+)
+
+type memoryExporter struct {
+	mu    sync.Mutex
+	spans []utils.SpanData
+}
+
+func (m *memoryExporter) Export(_ context.Context, spans []utils.SpanData) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.spans = append(m.spans, spans...)
+	return nil
+}
+
+func (m *memoryExporter) Shutdown(context.Context) error { return nil }
+
+func TestTrace(t *testing.T) {
+	t.Parallel()
+
+	t.Run("should continue inbound trace in span and logs", func(t *testing.T) {
+		t.Parallel()
+
+		// given
+		exporter := &memoryExporter{}
+		tracer := utils.Tracer("api", exporter)
+		lg := utils.RecordingLogger()
+		m := &Middleware{Logger: lg, Tracer: tracer}
+
+		mux := http.NewServeMux()
+		mux.HandleFunc("GET /api/orders/{id}", func(w http.ResponseWriter, r *http.Request) {
+			lg.Log(r.Context(), "loading order")
+			w.WriteHeader(http.StatusBadGateway)
+		})
+
+		req := httptest.NewRequest(http.MethodGet, "/api/orders/42", nil)
+		req.Header.Set("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+
+		// method to test
+		m.Log(mux).ServeHTTP(httptest.NewRecorder(), req)
+		if err := tracer.Shutdown(context.Background()); err != nil {
+			t.Error(err.Error())
+			t.FailNow()
+		}
+
+		// assert
+		if len(exporter.spans) != 1 {
+			t.Errorf("expect 1 span, given %d", len(exporter.spans))
+			t.FailNow()
+		}
+		span := exporter.spans[0]
+		if span.SpanContext.TraceID.String() != "4bf92f3577b34da6a3ce929d0e0e4736" || span.Parent.String() != "00f067aa0ba902b7" {
+			t.Errorf("expect child of the inbound span, given %+v", span.SpanContext)
+		}
+		if span.Name != "GET /api/orders/{id}" || span.Kind != utils.SpanKindServer || span.Status != utils.StatusError {
+			t.Errorf("expect errored server span named after the route, given %s %d %d", span.Name, span.Kind, span.Status)
+		}
+
+		for _, e := range lg.Entries() {
+			if e.TraceId != span.SpanContext.TraceID.String() || e.SpanId != span.SpanContext.SpanID.String() {
+				t.Errorf("expect %q to carry the request span, given %s %s", e.Info, e.TraceId, e.SpanId)
+			}
+		}
+	})
+
+	t.Run("should start a trace without a tracer", func(t *testing.T) {
+		t.Parallel()
+
+		// given
+		m := &Middleware{Logger: utils.RecordingLogger()}
+		var b *utils.RequestBody
+		h := m.Log(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			b = r.Context().Value(utils.RequestKey).(*utils.RequestBody)
+		}))
+		req := httptest.NewRequest(http.MethodGet, "/api", nil)
+		req.Header.Set("traceparent", "malformed")
+
+		// method to test
+		h.ServeHTTP(httptest.NewRecorder(), req)
+
+		// assert
+		if len(b.TraceId) != 32 || len(b.SpanId) != 16 || b.TraceId == "4bf92f3577b34da6a3ce929d0e0e4736" {
+			t.Errorf("expect new trace and span ids, given %+v", b)
+		}
+	})
+}
@@ -0,0 +1,152 @@
+package middleware
+
+import (
+	"bufio"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/iTchTheRightSpot/utility/utils"
+)
+
+// stack wraps h in every middleware that replaces the response writer.
+func stack(m *Middleware, h http.Handler) http.Handler {
+	return m.Panic(m.Log(m.Error(m.Metrics(h))))
+}
+
+func TestResponseWriter(t *testing.T) {
+	t.Parallel()
+
+	t.Run("should expose exactly the interfaces of the wrapped writer", func(t *testing.T) {
+		t.Parallel()
+
+		// given
+		rec := httptest.NewRecorder()
+
+		// method to test
+		w := extend(&logWriter{ResponseWriter: rec, code: http.StatusOK})
+
+		// assert
+		if _, ok := w.(http.Flusher); !ok {
+			t.Error("expect http.Flusher of the recorder")
+		}
+		if _, ok := w.(http.Hijacker); ok {
+			t.Error("expect no http.Hijacker")
+		}
+		if _, ok := w.(io.ReaderFrom); ok {
+			t.Error("expect no io.ReaderFrom")
+		}
+		if u, ok := w.(interface{ Unwrap() http.ResponseWriter }); !ok || u.Unwrap() != rec {
+			t.Error("expect Unwrap to return the recorder")
+		}
+	})
+
+	t.Run("should stream server sent events through the middleware", func(t *testing.T) {
+		t.Parallel()
+
+		// given
+		release := make(chan struct{})
+		m := &Middleware{Logger: utils.RecordingLogger(), Registry: utils.MetricsRegistry()}
+		srv := httptest.NewServer(stack(m, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "text/event-stream")
+			_, _ = w.Write([]byte("data: first\n\n"))
+			if err := http.NewResponseController(w).Flush(); err != nil {
+				t.Error(err.Error())
+			}
+			<-release
+			_, _ = w.Write([]byte("data: second\n\n"))
+		})))
+		defer srv.Close()
+
+		// method to test
+		res, err := http.Get(srv.URL)
+		if err != nil {
+			t.Error(err.Error())
+			t.FailNow()
+		}
+		defer func() { _ = res.Body.Close() }()
+
+		// assert
+		line, err := bufio.NewReader(res.Body).ReadString('\n')
+		close(release)
+		if err != nil || line != "data: first\n" {
+			t.Errorf("expect first event before the handler returns, given %q %v", line, err)
+		}
+	})
+
+	t.Run("should hijack connections through the middleware", func(t *testing.T) {
+		t.Parallel()
+
+		// given
+		m := &Middleware{Logger: utils.RecordingLogger(), Registry: utils.MetricsRegistry()}
+		srv := httptest.NewServer(stack(m, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			conn, rw, err := http.NewResponseController(w).Hijack()
+			if err != nil {
+				t.Error(err.Error())
+				return
+			}
+			defer func() { _ = conn.Close() }()
+			_, _ = rw.WriteString("HTTP/1.1 101 Switching Protocols\r\nUpgrade: websocket\r\nConnection: Upgrade\r\n\r\n")
+			_ = rw.Flush()
+		})))
+		defer srv.Close()
+
+		conn, err := net.Dial("tcp", srv.Listener.Addr().String())
+		if err != nil {
+			t.Error(err.Error())
+			t.FailNow()
+		}
+		defer func() { _ = conn.Close() }()
+
+		// method to test
+		_, _ = conn.Write([]byte("GET / HTTP/1.1\r\nHost: test\r\nUpgrade: websocket\r\nConnection: Upgrade\r\n\r\n"))
+
+		// assert
+		status, _ := bufio.NewReader(conn).ReadString('\n')
+		if status != "HTTP/1.1 101 Switching Protocols\r\n" {
+			t.Errorf("expect upgrade response, given %q", status)
+		}
+	})
+
+	t.Run("should copy bodies with ReadFrom and count the bytes", func(t *testing.T) {
+		t.Parallel()
+
+		// given
+		lg := utils.RecordingLogger()
+		m := &Middleware{Logger: lg, Registry: utils.MetricsRegistry()}
+		srv := httptest.NewServer(stack(m, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if _, ok := w.(io.ReaderFrom); !ok {
+				t.Error("expect io.ReaderFrom of the server writer")
+			}
+			_, _ = io.Copy(w, strings.NewReader("payload"))
+		})))
+		defer srv.Close()
+
+		// method to test
+		res, err := http.Get(srv.URL)
+		if err != nil {
+			t.Error(err.Error())
+			t.FailNow()
+		}
+		body, _ := io.ReadAll(res.Body)
+		_ = res.Body.Close()
+
+		// assert
+		if string(body) != "payload" {
+			t.Errorf("expect payload, given %q", body)
+		}
+		entries := lg.Find(utils.Query{Message: "request completed"})
+		if len(entries) != 1 {
+			t.Errorf("expect 1 access entry, given %d", len(entries))
+			t.FailNow()
+		}
+		for _, f := range entries[0].Fields {
+			if f.Key == "bytes" && f.Value != int64(7) {
+				t.Errorf("expect 7 bytes, given %v", f.Value)
+			}
+		}
+	})
+}
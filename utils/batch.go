@@ -0,0 +1,224 @@
+package utils
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+)
+
+type BatchConfig struct {
+	// Size is the number of entries shipped per request. Defaults to 100.
+	Size int
+	// Interval after which a partial batch is shipped. Defaults to 1 second.
+	Interval time.Duration
+	// QueueSize is the number of entries buffered while a batch is in flight.
+	// Defaults to 1000.
+	QueueSize int
+	// MaxRetries for a failed batch. Defaults to 3, negative disables retries.
+	MaxRetries int
+	// Backoff before the first retry, doubled on every attempt. Defaults to 200ms.
+	Backoff time.Duration
+	// Block makes Write wait for room in a full queue instead of dropping the entry.
+	Block bool
+}
+
+func (c BatchConfig) withDefaults() BatchConfig {
+	if c.Size <= 0 {
+		c.Size = 100
+	}
+	if c.Interval <= 0 {
+		c.Interval = time.Second
+	}
+	if c.QueueSize <= 0 {
+		c.QueueSize = 1000
+	}
+	if c.MaxRetries < 0 {
+		c.MaxRetries = 0
+	} else if c.MaxRetries == 0 {
+		c.MaxRetries = 3
+	}
+	if c.Backoff <= 0 {
+		c.Backoff = 200 * time.Millisecond
+	}
+	return c
+}
+
+// queued is an entry waiting to be shipped with the time it was logged.
+type queued struct {
+	entry *Entry
+	at    time.Time
+}
+
+// shipFunc sends a batch. It returns the entries worth retrying and the
+// number dropped for good e.g. rejected by the backend, explaining either in
+// err. Everything else in the batch was shipped.
+type shipFunc func(batch []queued) (retry []queued, dropped int, err error)
+
+var errQueueFull = errors.New("queue full, entry dropped")
+
+// queue metrics of every batched sink, labeled by sink name
+var (
+	sinkQueueLength = DefaultRegistry.Gauge("log_sink_queue_length", "Entries waiting to be shipped by a log sink.", "sink")
+	sinkShipped     = DefaultRegistry.Counter("log_sink_shipped_total", "Entries shipped by a log sink.", "sink")
+	sinkDropped     = DefaultRegistry.Counter("log_sink_dropped_total", "Entries dropped by a log sink on a full queue or failed delivery.", "sink")
+)
+
+// batcher buffers entries in a bounded queue and ships them from a single
+// goroutine, by size or interval, retrying with exponential backoff.
+type batcher struct {
+	name   string
+	cfg    BatchConfig
+	ship   shipFunc
+	queue  chan queued
+	mu     sync.RWMutex
+	closed bool
+	wg     sync.WaitGroup
+}
+
+func newBatcher(name string, cfg BatchConfig, ship shipFunc) *batcher {
+	cfg = cfg.withDefaults()
+	b := &batcher{name: name, cfg: cfg, ship: ship, queue: make(chan queued, cfg.QueueSize)}
+	b.wg.Add(1)
+	go b.run()
+	return b
+}
+
+func (b *batcher) add(e *Entry) error {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	if b.closed {
+		return fmt.Errorf("%s: closed", b.name)
+	}
+
+	// counted before the send so run never sees a negative length
+	q := queued{entry: e, at: entryTime(e)}
+	sinkQueueLength.Inc(b.name)
+	if b.cfg.Block {
+		b.queue <- q
+		return nil
+	}
+
+	select {
+	case b.queue <- q:
+		return nil
+	default:
+		sinkQueueLength.Dec(b.name)
+		sinkDropped.Inc(b.name)
+		return fmt.Errorf("%s: %w", b.name, errQueueFull)
+	}
+}
+
+func (b *batcher) run() {
+	defer b.wg.Done()
+
+	ticker := time.NewTicker(b.cfg.Interval)
+	defer ticker.Stop()
+
+	batch := make([]queued, 0, b.cfg.Size)
+	for {
+		select {
+		case q, ok := <-b.queue:
+			if !ok {
+				b.send(batch)
+				return
+			}
+			sinkQueueLength.Dec(b.name)
+			batch = append(batch, q)
+			if len(batch) >= b.cfg.Size {
+				b.send(batch)
+				batch = make([]queued, 0, b.cfg.Size)
+			}
+		case <-ticker.C:
+			if len(batch) > 0 {
+				b.send(batch)
+				batch = make([]queued, 0, b.cfg.Size)
+			}
+		}
+	}
+}
+
+func (b *batcher) send(batch []queued) {
+	if len(batch) == 0 {
+		return
+	}
+
+	backoff := b.cfg.Backoff
+	for attempt := 0; ; attempt++ {
+		retry, dropped, err := b.ship(batch)
+		if len(retry) > 0 && attempt == b.cfg.MaxRetries {
+			dropped += len(retry)
+			retry = nil
+		}
+		sinkShipped.Add(float64(len(batch)-len(retry)-dropped), b.name)
+		if dropped > 0 {
+			sinkDropped.Add(float64(dropped), b.name)
+			fmt.Printf("%s %s: dropped %d entries: %s\n", iCritical, b.name, dropped, err.Error())
+		}
+		if len(retry) == 0 {
+			return
+		}
+		time.Sleep(backoff)
+		backoff *= 2
+		batch = retry
+	}
+}
+
+// close stops accepting entries, ships everything still queued and waits.
+func (b *batcher) close() {
+	b.mu.Lock()
+	if b.closed {
+		b.mu.Unlock()
+		return
+	}
+	b.closed = true
+	close(b.queue)
+	b.mu.Unlock()
+
+	b.wg.Wait()
+}
+
+// statusError is returned when a backend answers with an unexpected status.
+type statusError struct {
+	code int
+	body string
+}
+
+func (e *statusError) Error() string {
+	return fmt.Sprintf("unexpected status %d: %s", e.code, e.body)
+}
+
+// retryable reports whether a request failing with code may succeed later.
+func retryable(code int) bool {
+	return code == http.StatusTooManyRequests || code >= http.StatusInternalServerError
+}
+
+// post sends body and converts a non 2xx response into a statusError.
+func post(client *http.Client, url, contentType string, headers map[string]string, body io.Reader) ([]byte, error) {
+	req, err := http.NewRequest(http.MethodPost, url, body)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", contentType)
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+
+	res, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = res.Body.Close() }()
+
+	by, err := io.ReadAll(io.LimitReader(res.Body, 1<<20))
+	if err != nil {
+		return nil, err
+	}
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		return by, &statusError{code: res.StatusCode, body: string(by)}
+	}
+	return by, nil
+}
@@ -0,0 +1,70 @@
+package utils
+
+import (
+	"fmt"
+	"runtime"
+	"strings"
+)
+
+// maxStackFrames caps how many frames a captured stack trace holds.
+const maxStackFrames = 32
+
+// WithCallerSkip skips additional frames when resolving the caller of a log
+// call. Use it when the logger is wrapped by helper functions.
+func WithCallerSkip(skip int) Option {
+	return func(o *options) {
+		o.callerSkip = skip
+	}
+}
+
+// WithStacktrace attaches a trimmed stack trace to ERROR and above entries.
+func WithStacktrace() Option {
+	return func(o *options) {
+		o.stacktrace = true
+	}
+}
+
+// callsite returns up to depth frames starting skip frames above the caller
+// of callsite.
+func callsite(skip, depth int) []runtime.Frame {
+	pcs := make([]uintptr, depth)
+	n := runtime.Callers(skip+2, pcs)
+	if n == 0 {
+		return nil
+	}
+
+	var res []runtime.Frame
+	frames := runtime.CallersFrames(pcs[:n])
+	for {
+		f, more := frames.Next()
+		res = append(res, f)
+		if !more {
+			break
+		}
+	}
+	return res
+}
+
+// shortPath keeps the last directory and file name e.g. utils/log.go
+func shortPath(file string) string {
+	i := strings.LastIndex(file, "/")
+	if i < 0 {
+		return file
+	}
+	if j := strings.LastIndex(file[:i], "/"); j >= 0 {
+		return file[j+1:]
+	}
+	return file
+}
+
+// stack renders frames one per line, dropping Go runtime internals.
+func stack(frames []runtime.Frame) string {
+	var sb strings.Builder
+	for _, f := range frames {
+		if strings.HasPrefix(f.Function, "runtime.") {
+			continue
+		}
+		sb.WriteString(fmt.Sprintf("%s\n\t%s:%d\n", f.Function, f.File, f.Line))
+	}
+	return strings.TrimSuffix(sb.String(), "\n")
+}
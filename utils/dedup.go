@@ -0,0 +1,108 @@
+package utils
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"regexp"
+	"sync"
+	"time"
+)
+
+var (
+	uuidPattern   = regexp.MustCompile(`[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}`)
+	hexPattern    = regexp.MustCompile(`\b0x[0-9a-fA-F]+\b|\b[0-9a-fA-F]{16,}\b`)
+	numberPattern = regexp.MustCompile(`\d+(\.\d+)?`)
+	quotedPattern = regexp.MustCompile(`"[^"]*"|'[^']*'`)
+)
+
+// template strips the variable parts of a message so entries that only differ
+// by ids, numbers or quoted values share a fingerprint.
+func template(message string) string {
+	message = uuidPattern.ReplaceAllString(message, "<uuid>")
+	message = hexPattern.ReplaceAllString(message, "<hex>")
+	message = quotedPattern.ReplaceAllString(message, "<str>")
+	return numberPattern.ReplaceAllString(message, "<n>")
+}
+
+// fingerprint identifies an entry by level, message template and caller.
+func fingerprint(e *Entry) string {
+	sum := sha1.Sum([]byte(string(e.Status) + "|" + template(e.Info) + "|" + e.Caller))
+	return hex.EncodeToString(sum[:8])
+}
+
+type occurrence struct {
+	first *Entry
+	count int
+}
+
+type deduper struct {
+	window time.Duration
+	// after schedules f once d elapses, time.AfterFunc outside tests
+	after func(d time.Duration, f func())
+	mu    sync.Mutex
+	seen  map[string]*occurrence
+}
+
+// WithDeduplication fingerprints ERROR and CRITICAL entries and forwards only
+// the first occurrence within window to the webhook and sinks. When the
+// window closes, a summary with the number of suppressed repeats is sent
+// instead of one message each. The logger output still receives every entry.
+func WithDeduplication(window time.Duration) Option {
+	return func(o *options) {
+		if window <= 0 {
+			window = 5 * time.Minute
+		}
+		o.deduper = &deduper{
+			window: window,
+			after:  func(d time.Duration, f func()) { time.AfterFunc(d, f) },
+			seen:   map[string]*occurrence{},
+		}
+	}
+}
+
+// allow reports whether e should be forwarded. date and emit are used to send
+// the summary once the window of a suppressed fingerprint closes.
+func (d *deduper) allow(e *Entry, date func() time.Time, emit func(*Entry)) bool {
+	if e.Status != iError && e.Status != iCritical {
+		return true
+	}
+
+	fp := fingerprint(e)
+	e.Fields = append(e.Fields, String("fingerprint", fp))
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if o, ok := d.seen[fp]; ok {
+		o.count++
+		return false
+	}
+
+	d.seen[fp] = &occurrence{first: e, count: 1}
+	d.after(d.window, func() {
+		if s := d.summary(fp, date()); s != nil {
+			emit(s)
+		}
+	})
+	return true
+}
+
+// summary removes fp and returns an entry describing its suppressed repeats,
+// or nil when the entry was not repeated.
+func (d *deduper) summary(fp string, now time.Time) *Entry {
+	d.mu.Lock()
+	o, ok := d.seen[fp]
+	delete(d.seen, fp)
+	d.mu.Unlock()
+
+	if !ok || o.count < 2 {
+		return nil
+	}
+
+	s := *o.first
+	s.Time = now
+	s.Info = fmt.Sprintf("this error occurred %d times in the last %s: %s", o.count, d.window, o.first.Info)
+	s.Fields = append(append([]Field{}, o.first.Fields...), Int("occurrences", o.count), Duration("window", d.window))
+	return &s
+}
@@ -0,0 +1,114 @@
+package utils
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+type memorySink struct {
+	mu      sync.Mutex
+	entries []*Entry
+}
+
+func (m *memorySink) Write(e *Entry) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.entries = append(m.entries, e)
+	return nil
+}
+
+func (m *memorySink) Close() error { return nil }
+
+func (m *memorySink) all() []*Entry {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]*Entry{}, m.entries...)
+}
+
+func TestDeduplication(t *testing.T) {
+	t.Parallel()
+
+	t.Run("template ignores variable parts", func(t *testing.T) {
+		t.Parallel()
+
+		// given
+		a := template(`user 42 "jane" failed 5bd0c6a4-2c3f-4d4f-9e1e-6a3d6f0b1c2d after 1.5s`)
+		b := template(`user 7 "john" failed 0c3cd9b2-8e7f-4b1a-a0b3-1f2e3d4c5b6a after 30s`)
+
+		// assert
+		if a != b {
+			t.Errorf("expect equal templates, given %s and %s", a, b)
+		}
+	})
+
+	t.Run("should suppress repeats and emit summary", func(t *testing.T) {
+		t.Parallel()
+
+		// given
+		sink := &memorySink{}
+		out := new(strings.Builder)
+		lg := DevLogger("UTC", WithOutput(out), WithSink("memory", sink), WithDeduplication(time.Minute))
+		var closes []func()
+		lg.(*mockLogger).deduper.after = func(d time.Duration, f func()) {
+			if d != time.Minute {
+				t.Errorf("expect window of %s, given %s", time.Minute, d)
+			}
+			closes = append(closes, f)
+		}
+
+		// method to test
+		for i := 0; i < 5; i++ {
+			lg.Error(context.Background(), fmt.Sprintf("connection %d refused", i))
+		}
+		lg.Log(context.Background(), "not deduplicated")
+		lg.Log(context.Background(), "not deduplicated")
+
+		// assert
+		if n := len(sink.all()); n != 3 {
+			t.Errorf("expect 1 error and 2 logs forwarded, given %d", n)
+			t.FailNow()
+		}
+		if n := strings.Count(out.String(), "refused"); n != 5 {
+			t.Errorf("expect every error in output, given %d", n)
+			t.FailNow()
+		}
+
+		if len(closes) != 1 {
+			t.Errorf("expect 1 window scheduled, given %d", len(closes))
+			t.FailNow()
+		}
+		closes[0]()
+
+		entries := sink.all()
+		if len(entries) != 4 {
+			t.Errorf("expect summary entry, given %d entries", len(entries))
+			t.FailNow()
+		}
+		summary := entries[3]
+		if !strings.HasPrefix(summary.Info, "this error occurred 5 times") {
+			t.Errorf("expect summary message, given %s", summary.Info)
+		}
+	})
+
+	t.Run("different callers are not deduplicated", func(t *testing.T) {
+		t.Parallel()
+
+		// given
+		sink := &memorySink{}
+		lg := DevLogger("UTC", WithOutput(io.Discard), WithSink("memory", sink), WithDeduplication(time.Minute))
+
+		// method to test
+		lg.Critical(context.Background(), "timeout")
+		lg.Critical(context.Background(), "timeout")
+
+		// assert
+		if n := len(sink.all()); n != 2 {
+			t.Errorf("expect 2 entries, given %d", n)
+		}
+	})
+}
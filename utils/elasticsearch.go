@@ -0,0 +1,149 @@
+package utils
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+type ElasticsearchConfig struct {
+	// URL of the Elasticsearch or OpenSearch cluster e.g. http://localhost:9200
+	URL string
+	// Index entries are written to. Defaults to logs.
+	Index string
+	// DailyIndex appends the entry date to Index e.g. logs-2006.01.02
+	DailyIndex bool
+	// Headers are added to every bulk request e.g. Authorization: ApiKey ...
+	Headers map[string]string
+	// Client defaults to an http.Client with a 5 second timeout.
+	Client *http.Client
+	Batch  BatchConfig
+}
+
+type elasticsearchSink struct {
+	cfg     ElasticsearchConfig
+	url     string
+	batcher *batcher
+}
+
+// ElasticsearchSink ships entries through the _bulk API. Items rejected with
+// 429 or 5xx are retried, other rejected items are dropped and reported.
+func ElasticsearchSink(cfg ElasticsearchConfig) (Sink, error) {
+	if cfg.URL == "" {
+		return nil, errors.New("elasticsearch sink: url is required")
+	}
+	if cfg.Index == "" {
+		cfg.Index = "logs"
+	}
+	if cfg.Client == nil {
+		cfg.Client = &http.Client{Timeout: 5 * time.Second}
+	}
+
+	s := &elasticsearchSink{cfg: cfg, url: strings.TrimSuffix(cfg.URL, "/") + "/_bulk"}
+	s.batcher = newBatcher("elasticsearch sink", cfg.Batch, s.bulk)
+	return s, nil
+}
+
+func (s *elasticsearchSink) Write(e *Entry) error {
+	return s.batcher.add(e)
+}
+
+func (s *elasticsearchSink) Close() error {
+	s.batcher.close()
+	return nil
+}
+
+func (s *elasticsearchSink) index(t time.Time) string {
+	if s.cfg.DailyIndex {
+		return s.cfg.Index + "-" + t.UTC().Format("2006.01.02")
+	}
+	return s.cfg.Index
+}
+
+// document is the entry with an @timestamp Elasticsearch can index on.
+func document(q queued) ([]byte, error) {
+	by, err := json.Marshal(q.entry)
+	if err != nil {
+		return nil, err
+	}
+	ts, _ := json.Marshal(q.at.UTC().Format(time.RFC3339Nano))
+	return append(append([]byte(`{"@timestamp":`), ts...), append([]byte{','}, by[1:]...)...), nil
+}
+
+type bulkResponse struct {
+	Errors bool `json:"errors"`
+	Items  []map[string]struct {
+		Status int `json:"status"`
+		Error  struct {
+			Type   string `json:"type"`
+			Reason string `json:"reason"`
+		} `json:"error"`
+	} `json:"items"`
+}
+
+func (s *elasticsearchSink) bulk(batch []queued) ([]queued, int, error) {
+	buf := new(bytes.Buffer)
+	sent := make([]queued, 0, len(batch))
+	var encodeErr error
+	for _, q := range batch {
+		doc, err := document(q)
+		if err != nil {
+			encodeErr = err
+			continue
+		}
+		action, _ := json.Marshal(map[string]map[string]string{"index": {"_index": s.index(q.at)}})
+		buf.Write(action)
+		buf.WriteByte('\n')
+		buf.Write(doc)
+		buf.WriteByte('\n')
+		sent = append(sent, q)
+	}
+
+	unencoded := len(batch) - len(sent)
+	by, err := post(s.cfg.Client, s.url, "application/x-ndjson", s.cfg.Headers, buf)
+	if err != nil {
+		var se *statusError
+		if errors.As(err, &se) && !retryable(se.code) {
+			return nil, len(batch), err
+		}
+		return sent, unencoded, err
+	}
+
+	var res bulkResponse
+	if err = json.Unmarshal(by, &res); err != nil {
+		return nil, len(batch), err
+	}
+	if !res.Errors {
+		return nil, unencoded, encodeErr
+	}
+
+	var retry []queued
+	var reason string
+	rejected := 0
+	for i, item := range res.Items {
+		if i >= len(sent) {
+			break
+		}
+		for _, r := range item {
+			if r.Status < 300 {
+				continue
+			}
+			rejected++
+			reason = r.Error.Type + ": " + r.Error.Reason
+			if retryable(r.Status) {
+				retry = append(retry, sent[i])
+			}
+		}
+	}
+	if rejected == 0 {
+		return nil, unencoded, encodeErr
+	}
+
+	// items rejected with a non retryable status are dropped for good
+	err = fmt.Errorf("%d of %d items rejected, last %s", rejected, len(sent), reason)
+	return retry, unencoded + rejected - len(retry), err
+}
@@ -0,0 +1,221 @@
+package utils
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Encoder turns an Entry into the bytes written to the logger output. Every
+// encoder terminates the entry with a newline so one entry is one line.
+type Encoder interface {
+	Encode(e *Entry) ([]byte, error)
+}
+
+// EncoderOption configures a single encoder.
+type EncoderOption func(*encoderConfig)
+
+type encoderConfig struct {
+	timeFormat string
+}
+
+// EncodeTime renders timestamps with layout, a time layout or UnixMillis,
+// instead of the format of the logger.
+func EncodeTime(layout string) EncoderOption {
+	return func(c *encoderConfig) {
+		c.timeFormat = layout
+	}
+}
+
+func newEncoderConfig(opts []EncoderOption) encoderConfig {
+	var c encoderConfig
+	for _, opt := range opts {
+		opt(&c)
+	}
+	return c
+}
+
+// time renders the timestamp of e with the encoder layout when one is set.
+func (c encoderConfig) time(e *Entry) string {
+	if c.timeFormat == "" {
+		return e.Timestamp()
+	}
+	return fmt.Sprint(formatTime(e.Time, c.timeFormat))
+}
+
+type jsonEncoder struct {
+	cfg encoderConfig
+}
+
+// JSONEncoder writes each entry as a compact JSON object on its own line.
+func JSONEncoder(opts ...EncoderOption) Encoder {
+	return jsonEncoder{cfg: newEncoderConfig(opts)}
+}
+
+func (j jsonEncoder) Encode(e *Entry) ([]byte, error) {
+	if j.cfg.timeFormat != "" {
+		// entries are shared with other outputs so never modify e
+		c := *e
+		c.timeFormat = j.cfg.timeFormat
+		e = &c
+	}
+	by, err := json.Marshal(e)
+	if err != nil {
+		return nil, err
+	}
+	return append(by, '\n'), nil
+}
+
+type logfmtEncoder struct {
+	cfg encoderConfig
+}
+
+// LogfmtEncoder writes each entry as space separated key=value pairs.
+func LogfmtEncoder(opts ...EncoderOption) Encoder {
+	return logfmtEncoder{cfg: newEncoderConfig(opts)}
+}
+
+func (l logfmtEncoder) Encode(e *Entry) ([]byte, error) {
+	buf := new(bytes.Buffer)
+	put := func(key, value string) {
+		if buf.Len() > 0 {
+			buf.WriteByte(' ')
+		}
+		buf.WriteString(logfmtKey(key))
+		buf.WriteByte('=')
+		buf.WriteString(logfmtValue(value))
+	}
+
+	put("time", l.cfg.time(e))
+	put("status", string(e.Status))
+	for _, kv := range [][2]string{
+		{"request_id", e.Id},
+		{"trace_id", e.TraceId},
+		{"span_id", e.SpanId},
+		{"ip_address", e.Ip},
+		{"method", e.Method},
+		{"path", e.Path},
+	} {
+		if kv[1] != "" {
+			put(kv[0], kv[1])
+		}
+	}
+	put("info", e.Info)
+	for _, kv := range [][2]string{{"caller", e.Caller}, {"function", e.Function}, {"stack", e.Stack}} {
+		if kv[1] != "" {
+			put(kv[0], kv[1])
+		}
+	}
+	for _, f := range e.Fields {
+		put(fieldKey(f.Key), f.String())
+	}
+
+	buf.WriteByte('\n')
+	return buf.Bytes(), nil
+}
+
+// logfmtKey drops characters that would make a key ambiguous to parsers.
+func logfmtKey(key string) string {
+	return strings.Map(func(r rune) rune {
+		if r <= ' ' || r == '=' || r == '"' {
+			return '_'
+		}
+		return r
+	}, key)
+}
+
+func logfmtValue(value string) string {
+	if value == "" {
+		return `""`
+	}
+	if strings.ContainsAny(value, " =\"\\") || strings.IndexFunc(value, func(r rune) bool { return r < ' ' }) >= 0 {
+		return strconv.Quote(value)
+	}
+	return value
+}
+
+const (
+	colorReset  = "\033[0m"
+	colorRed    = "\033[31m"
+	colorYellow = "\033[33m"
+	colorBlue   = "\033[34m"
+	colorGray   = "\033[90m"
+	colorBold   = "\033[1;31m"
+)
+
+type consoleEncoder struct {
+	colored bool
+	cfg     encoderConfig
+}
+
+// ConsoleEncoder writes human-friendly lines for local development. When
+// colored is true the level is highlighted using ANSI escape codes.
+func ConsoleEncoder(colored bool, opts ...EncoderOption) Encoder {
+	return consoleEncoder{colored: colored, cfg: newEncoderConfig(opts)}
+}
+
+func (c consoleEncoder) paint(color, s string) string {
+	if !c.colored {
+		return s
+	}
+	return color + s + colorReset
+}
+
+func (c consoleEncoder) levelColor(t logType) string {
+	switch t {
+	case iFatal:
+		return colorBold
+	case iCritical, iError:
+		return colorRed
+	case iLog:
+		return colorBlue
+	case iDebug:
+		return colorGray
+	default:
+		return colorYellow
+	}
+}
+
+func (c consoleEncoder) Encode(e *Entry) ([]byte, error) {
+	var sb strings.Builder
+	sb.WriteString(c.paint(colorGray, c.cfg.time(e)))
+	sb.WriteByte(' ')
+	// levels line up at 7 columns, CRITICAL overflows and still gets a separator
+	sb.WriteString(c.paint(c.levelColor(e.Status), fmt.Sprintf("%-7s", e.Status)))
+	sb.WriteByte(' ')
+	if e.Method != "" || e.Path != "" {
+		sb.WriteString(strings.TrimSpace(e.Method + " " + e.Path))
+		sb.WriteByte(' ')
+	}
+	sb.WriteString(e.Info)
+
+	if e.Id != "" {
+		sb.WriteString(c.paint(colorGray, " request_id="))
+		sb.WriteString(e.Id)
+	}
+	if e.TraceId != "" {
+		sb.WriteString(c.paint(colorGray, " trace_id="))
+		sb.WriteString(e.TraceId)
+	}
+	if e.Ip != "" {
+		sb.WriteString(c.paint(colorGray, " ip_address="))
+		sb.WriteString(e.Ip)
+	}
+	for _, f := range e.Fields {
+		sb.WriteString(c.paint(colorGray, " "+fieldKey(f.Key)+"="))
+		sb.WriteString(logfmtValue(f.String()))
+	}
+	if e.Caller != "" {
+		sb.WriteString(c.paint(colorGray, " caller="+e.Caller))
+	}
+
+	sb.WriteByte('\n')
+	if e.Stack != "" {
+		// development output favours readability over one entry per line
+		sb.WriteString(c.paint(colorGray, e.Stack))
+		sb.WriteByte('\n')
+	}
+	return []byte(sb.String()), nil
+}
@@ -3,7 +3,10 @@ package utils
 import (
 	"encoding/json"
 	"errors"
+	"math"
 	"net/http"
+	"strconv"
+	"time"
 )
 
 type NotFoundError struct {
@@ -72,6 +75,182 @@ func (e *ServerError) Error() string {
 	return e.Message
 }
 
+// DetailedError is implemented by errors carrying a stable machine readable
+// code e.g. "order_already_paid" and details, both serialized by
+// ErrorResponse. The errors below implement it and keep their cause in Err for
+// errors.Is and errors.As, it is never sent to clients.
+type DetailedError interface {
+	error
+	ErrorCode() string
+	ErrorDetails() map[string]interface{}
+}
+
+// ConflictError is returned when the request conflicts with the current state of a resource.
+type ConflictError struct {
+	Message string
+	Code    string
+	Err     error
+	Details map[string]interface{}
+}
+
+func (e *ConflictError) Error() string {
+	if e.Message == "" {
+		return "conflict"
+	}
+	return e.Message
+}
+
+func (e *ConflictError) Unwrap() error                        { return e.Err }
+func (e *ConflictError) ErrorCode() string                    { return e.Code }
+func (e *ConflictError) ErrorDetails() map[string]interface{} { return e.Details }
+
+// GoneError is returned when a resource existed but was removed for good.
+type GoneError struct {
+	Message string
+	Code    string
+	Err     error
+	Details map[string]interface{}
+}
+
+func (e *GoneError) Error() string {
+	if e.Message == "" {
+		return "gone"
+	}
+	return e.Message
+}
+
+func (e *GoneError) Unwrap() error                        { return e.Err }
+func (e *GoneError) ErrorCode() string                    { return e.Code }
+func (e *GoneError) ErrorDetails() map[string]interface{} { return e.Details }
+
+// PayloadTooLargeError is returned when a request body exceeds the accepted size.
+type PayloadTooLargeError struct {
+	Message string
+	Code    string
+	Err     error
+	Details map[string]interface{}
+}
+
+func (e *PayloadTooLargeError) Error() string {
+	if e.Message == "" {
+		return "payload too large"
+	}
+	return e.Message
+}
+
+func (e *PayloadTooLargeError) Unwrap() error                        { return e.Err }
+func (e *PayloadTooLargeError) ErrorCode() string                    { return e.Code }
+func (e *PayloadTooLargeError) ErrorDetails() map[string]interface{} { return e.Details }
+
+// UnprocessableEntityError is returned when a well formed request fails validation.
+type UnprocessableEntityError struct {
+	Message string
+	Code    string
+	Err     error
+	Details map[string]interface{}
+}
+
+func (e *UnprocessableEntityError) Error() string {
+	if e.Message == "" {
+		return "unprocessable entity"
+	}
+	return e.Message
+}
+
+func (e *UnprocessableEntityError) Unwrap() error                        { return e.Err }
+func (e *UnprocessableEntityError) ErrorCode() string                    { return e.Code }
+func (e *UnprocessableEntityError) ErrorDetails() map[string]interface{} { return e.Details }
+
+// TooManyRequestsError is returned when a client exceeds a rate limit.
+// RetryAfter is how long the client should wait before trying again.
+type TooManyRequestsError struct {
+	Message    string
+	Code       string
+	Err        error
+	Details    map[string]interface{}
+	RetryAfter time.Duration
+}
+
+func (e *TooManyRequestsError) Error() string {
+	if e.Message == "" {
+		return "too many requests"
+	}
+	return e.Message
+}
+
+func (e *TooManyRequestsError) Unwrap() error                        { return e.Err }
+func (e *TooManyRequestsError) ErrorCode() string                    { return e.Code }
+func (e *TooManyRequestsError) ErrorDetails() map[string]interface{} { return e.Details }
+
+// ServiceUnavailableError is returned when a dependency is down or the service is overloaded.
+// RetryAfter, when set, tells clients when to try again.
+type ServiceUnavailableError struct {
+	Message    string
+	Code       string
+	Err        error
+	Details    map[string]interface{}
+	RetryAfter time.Duration
+}
+
+func (e *ServiceUnavailableError) Error() string {
+	if e.Message == "" {
+		return "service unavailable"
+	}
+	return e.Message
+}
+
+func (e *ServiceUnavailableError) Unwrap() error                        { return e.Err }
+func (e *ServiceUnavailableError) ErrorCode() string                    { return e.Code }
+func (e *ServiceUnavailableError) ErrorDetails() map[string]interface{} { return e.Details }
+
+// GatewayTimeoutError is returned when an upstream service did not answer in time.
+type GatewayTimeoutError struct {
+	Message string
+	Code    string
+	Err     error
+	Details map[string]interface{}
+}
+
+func (e *GatewayTimeoutError) Error() string {
+	if e.Message == "" {
+		return "gateway timeout"
+	}
+	return e.Message
+}
+
+func (e *GatewayTimeoutError) Unwrap() error                        { return e.Err }
+func (e *GatewayTimeoutError) ErrorCode() string                    { return e.Code }
+func (e *GatewayTimeoutError) ErrorDetails() map[string]interface{} { return e.Details }
+
+// NotImplementedError is returned when a feature is not supported yet.
+type NotImplementedError struct {
+	Message string
+	Code    string
+	Err     error
+	Details map[string]interface{}
+}
+
+func (e *NotImplementedError) Error() string {
+	if e.Message == "" {
+		return "not implemented"
+	}
+	return e.Message
+}
+
+func (e *NotImplementedError) Unwrap() error                        { return e.Err }
+func (e *NotImplementedError) ErrorCode() string                    { return e.Code }
+func (e *NotImplementedError) ErrorDetails() map[string]interface{} { return e.Details }
+
+// asProblem returns the Problem in the chain of err.
+func asProblem(err error) (*Problem, bool) {
+	var p *Problem
+	ok := errors.As(err, &p)
+	return p, ok
+}
+
+// errorStatus matches the chain of err with errors.As. The original errors
+// keep their order so existing chains answer as before, the catalogue added
+// later is only consulted when none of them is present.
 func errorStatus(err error) int {
 	var notFoundError *NotFoundError
 	var insertionError *InsertionError
@@ -79,6 +258,14 @@ func errorStatus(err error) int {
 	var authenticationError *AuthenticationError
 	var accessDeniedError *AccessDeniedError
 	var serverError *ServerError
+	var conflictError *ConflictError
+	var goneError *GoneError
+	var payloadTooLargeError *PayloadTooLargeError
+	var unprocessableEntityError *UnprocessableEntityError
+	var tooManyRequestsError *TooManyRequestsError
+	var notImplementedError *NotImplementedError
+	var serviceUnavailableError *ServiceUnavailableError
+	var gatewayTimeoutError *GatewayTimeoutError
 	switch {
 	case errors.As(err, &notFoundError):
 		return http.StatusNotFound
@@ -92,17 +279,87 @@ func errorStatus(err error) int {
 		return http.StatusForbidden
 	case errors.As(err, &serverError):
 		return http.StatusInternalServerError
+	case errors.As(err, &conflictError):
+		return http.StatusConflict
+	case errors.As(err, &goneError):
+		return http.StatusGone
+	case errors.As(err, &payloadTooLargeError):
+		return http.StatusRequestEntityTooLarge
+	case errors.As(err, &unprocessableEntityError):
+		return http.StatusUnprocessableEntity
+	case errors.As(err, &tooManyRequestsError):
+		return http.StatusTooManyRequests
+	case errors.As(err, &notImplementedError):
+		return http.StatusNotImplemented
+	case errors.As(err, &serviceUnavailableError):
+		return http.StatusServiceUnavailable
+	case errors.As(err, &gatewayTimeoutError):
+		return http.StatusGatewayTimeout
 	default:
 		return http.StatusInternalServerError
 	}
 }
 
+// describe returns how err is answered. A Problem states its own status,
+// then registered errors are consulted before the built-in ones.
+func describe(err error) ErrorMapping {
+	if p, ok := asProblem(err); ok && p.Status != 0 {
+		return ErrorMapping{Status: p.Status, Message: err.Error()}
+	}
+	if m, ok := registeredError(err); ok {
+		if m.Message == "" {
+			m.Message = err.Error()
+		}
+		return m
+	}
+
+	m := ErrorMapping{Status: errorStatus(err), Message: err.Error()}
+	var detailed DetailedError
+	if errors.As(err, &detailed) {
+		m.Code, m.Details = detailed.ErrorCode(), detailed.ErrorDetails()
+	}
+	if d := retryAfter(err); d > 0 {
+		// Retry-After is in whole seconds so round up to never invite an early retry
+		m.Header = http.Header{"Retry-After": {strconv.Itoa(int(math.Ceil(d.Seconds())))}}
+	}
+	return m
+}
+
+func retryAfter(err error) time.Duration {
+	var tooManyRequestsError *TooManyRequestsError
+	var serviceUnavailableError *ServiceUnavailableError
+	switch {
+	case errors.As(err, &tooManyRequestsError):
+		return tooManyRequestsError.RetryAfter
+	case errors.As(err, &serviceUnavailableError):
+		return serviceUnavailableError.RetryAfter
+	}
+	return 0
+}
+
+// errorHeaders adds the headers of m to w.
+func errorHeaders(w http.ResponseWriter, m ErrorMapping) {
+	for k, values := range m.Header {
+		for _, v := range values {
+			w.Header().Add(k, v)
+		}
+	}
+}
+
 func ErrorResponse(w http.ResponseWriter, err error) {
-	code := errorStatus(err)
+	m := describe(err)
+	errorHeaders(w, m)
 	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(code)
+	w.WriteHeader(m.Status)
 
-	if er := json.NewEncoder(w).Encode(map[string]string{"message": err.Error()}); er != nil {
-		http.Error(w, err.Error(), code)
+	body := map[string]interface{}{"message": m.Message}
+	if m.Code != "" {
+		body["code"] = m.Code
+	}
+	if len(m.Details) > 0 {
+		body["details"] = m.Details
+	}
+	if er := json.NewEncoder(w).Encode(body); er != nil {
+		http.Error(w, m.Message, m.Status)
 	}
 }
@@ -0,0 +1,120 @@
+package utils
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestErrorResponse(t *testing.T) {
+	t.Parallel()
+
+	t.Run("should map the catalogue to statuses", func(t *testing.T) {
+		t.Parallel()
+
+		cases := []struct {
+			err    error
+			status int
+		}{
+			{&ConflictError{}, http.StatusConflict},
+			{&GoneError{}, http.StatusGone},
+			{&PayloadTooLargeError{}, http.StatusRequestEntityTooLarge},
+			{&UnprocessableEntityError{}, http.StatusUnprocessableEntity},
+			{&TooManyRequestsError{}, http.StatusTooManyRequests},
+			{&ServiceUnavailableError{}, http.StatusServiceUnavailable},
+			{&GatewayTimeoutError{}, http.StatusGatewayTimeout},
+			{&NotImplementedError{}, http.StatusNotImplemented},
+			{&GoneError{Err: errors.New("deleted")}, http.StatusGone},
+			{errors.Join(errors.New("first"), &BadRequestError{}), http.StatusBadRequest},
+		}
+
+		for _, c := range cases {
+			// method to test
+			w := httptest.NewRecorder()
+			ErrorResponse(w, c.err)
+
+			// assert
+			if w.Code != c.status {
+				t.Errorf("%T: expect %d, given %d", c.err, c.status, w.Code)
+			}
+		}
+	})
+
+	t.Run("should keep the priority of the original errors in mixed chains", func(t *testing.T) {
+		t.Parallel()
+
+		cases := []struct {
+			err    error
+			status int
+		}{
+			{errors.Join(&ServerError{}, &NotFoundError{}), http.StatusNotFound},
+			{errors.Join(&AccessDeniedError{}, &BadRequestError{}), http.StatusBadRequest},
+			{fmt.Errorf("lookup: %w", &InsertionError{Message: "duplicate"}), http.StatusConflict},
+			{&GoneError{Err: &NotFoundError{}}, http.StatusNotFound},
+			{errors.Join(&ServiceUnavailableError{}, &ServerError{}), http.StatusInternalServerError},
+			{errors.Join(&GatewayTimeoutError{}, &ConflictError{}), http.StatusConflict},
+		}
+
+		for _, c := range cases {
+			// method to test
+			w := httptest.NewRecorder()
+			ErrorResponse(w, c.err)
+
+			// assert
+			if w.Code != c.status {
+				t.Errorf("%v: expect %d, given %d", c.err, c.status, w.Code)
+			}
+		}
+	})
+
+	t.Run("should serialize code and details but not the cause", func(t *testing.T) {
+		t.Parallel()
+
+		// given
+		cause := errors.New("pq: duplicate key value violates unique constraint")
+		err := fmt.Errorf("create order: %w", &UnprocessableEntityError{
+			Message: "invalid order",
+			Code:    "invalid_order",
+			Err:     cause,
+			Details: map[string]interface{}{"quantity": "must be positive"},
+		})
+
+		// method to test
+		w := httptest.NewRecorder()
+		ErrorResponse(w, err)
+
+		// assert
+		if !errors.Is(err, cause) {
+			t.Error("expect cause in the chain")
+		}
+		var body struct {
+			Message string            `json:"message"`
+			Code    string            `json:"code"`
+			Details map[string]string `json:"details"`
+		}
+		if er := json.Unmarshal(w.Body.Bytes(), &body); er != nil {
+			t.Error(er.Error())
+			t.FailNow()
+		}
+		if body.Message != "create order: invalid order" || body.Code != "invalid_order" || body.Details["quantity"] != "must be positive" {
+			t.Errorf("expect message, code and details, given %s", w.Body.String())
+		}
+	})
+
+	t.Run("should set Retry-After on service unavailable", func(t *testing.T) {
+		t.Parallel()
+
+		// method to test
+		w := httptest.NewRecorder()
+		ErrorResponse(w, &ServiceUnavailableError{RetryAfter: 30 * time.Second})
+
+		// assert
+		if w.Header().Get("Retry-After") != "30" {
+			t.Errorf("expect Retry-After 30, given %q", w.Header().Get("Retry-After"))
+		}
+	})
+}
@@ -0,0 +1,64 @@
+package utils
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+)
+
+// ErrorMapping is how ErrorResponse answers a registered error.
+type ErrorMapping struct {
+	Status int
+	// Message is sent instead of the error text, keeping internal details
+	// private. Empty sends the error text.
+	Message string
+	// Code is a stable machine readable code e.g. "quota_exceeded".
+	Code string
+	// Details are sent with the message e.g. the fields failing validation.
+	Details map[string]interface{}
+	// Header is added to the response e.g. Retry-After or WWW-Authenticate.
+	Header http.Header
+}
+
+type errorRule struct {
+	match   func(error) bool
+	mapping ErrorMapping
+}
+
+// errorRules are consulted in registration order before the built-in errors.
+var errorRules struct {
+	mu    sync.RWMutex
+	rules []errorRule
+}
+
+// RegisterError maps every error with a T in its chain to m e.g.
+// RegisterError[*PaymentRequiredError](ErrorMapping{Status: 402}).
+func RegisterError[T error](m ErrorMapping) {
+	RegisterErrorFunc(func(err error) bool {
+		var target T
+		return errors.As(err, &target)
+	}, m)
+}
+
+// RegisterErrorFunc maps every error match accepts to m. It panics on a
+// status outside 400 to 599 as registration happens at start up.
+func RegisterErrorFunc(match func(error) bool, m ErrorMapping) {
+	if m.Status < 400 || m.Status > 599 {
+		panic(fmt.Sprintf("errors: invalid status %d", m.Status))
+	}
+	errorRules.mu.Lock()
+	defer errorRules.mu.Unlock()
+	errorRules.rules = append(errorRules.rules, errorRule{match: match, mapping: m})
+}
+
+func registeredError(err error) (ErrorMapping, bool) {
+	errorRules.mu.RLock()
+	defer errorRules.mu.RUnlock()
+	for _, r := range errorRules.rules {
+		if r.match(err) {
+			return r.mapping, true
+		}
+	}
+	return ErrorMapping{}, false
+}
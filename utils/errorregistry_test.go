@@ -0,0 +1,98 @@
+package utils
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+type paymentRequiredError struct{ amount int }
+
+func (e *paymentRequiredError) Error() string {
+	return fmt.Sprintf("card declined for %d", e.amount)
+}
+
+var errQuotaExceeded = errors.New("quota exceeded for tenant 42")
+
+// resetErrorRules removes the mappings registered by a test.
+func resetErrorRules() {
+	errorRules.mu.Lock()
+	defer errorRules.mu.Unlock()
+	errorRules.rules = nil
+}
+
+// TestErrorRegistry is not parallel as the registered mappings are global and
+// would change the responses of other tests.
+func TestErrorRegistry(t *testing.T) {
+	t.Cleanup(resetErrorRules)
+	RegisterError[*paymentRequiredError](ErrorMapping{Status: http.StatusPaymentRequired, Code: "payment_required"})
+	RegisterErrorFunc(func(err error) bool { return errors.Is(err, errQuotaExceeded) }, ErrorMapping{
+		Status:  http.StatusForbidden,
+		Message: "quota exceeded",
+		Code:    "quota_exceeded",
+		Header:  http.Header{"X-Quota-Reset": {"3600"}},
+	})
+
+	t.Run("should answer registered types and matchers", func(t *testing.T) {
+		t.Parallel()
+
+		cases := []struct {
+			err    error
+			status int
+			body   string
+			header string
+		}{
+			{fmt.Errorf("checkout: %w", &paymentRequiredError{amount: 30}), http.StatusPaymentRequired, `{"code":"payment_required","message":"checkout: card declined for 30"}`, ""},
+			{fmt.Errorf("upload: %w", errQuotaExceeded), http.StatusForbidden, `{"code":"quota_exceeded","message":"quota exceeded"}`, "3600"},
+			{&NotFoundError{}, http.StatusNotFound, `{"message":"not found"}`, ""},
+			{errors.New("unregistered"), http.StatusInternalServerError, `{"message":"unregistered"}`, ""},
+		}
+
+		for _, c := range cases {
+			// method to test
+			w := httptest.NewRecorder()
+			ErrorResponse(w, c.err)
+
+			// assert
+			if w.Code != c.status || strings.TrimSpace(w.Body.String()) != c.body {
+				t.Errorf("%v: expect %d %s, given %d %s", c.err, c.status, c.body, w.Code, w.Body.String())
+			}
+			if w.Header().Get("X-Quota-Reset") != c.header {
+				t.Errorf("%v: expect header %q, given %q", c.err, c.header, w.Header().Get("X-Quota-Reset"))
+			}
+		}
+	})
+
+	t.Run("should add code to problem details", func(t *testing.T) {
+		t.Parallel()
+
+		// given
+		r := httptest.NewRequest(http.MethodGet, "/upload", nil)
+		r = r.WithContext(ContextWithErrorConfig(r.Context(), &ErrorConfig{Format: ProblemFormat}))
+
+		// method to test
+		w := httptest.NewRecorder()
+		WriteError(w, r, errQuotaExceeded)
+
+		// assert
+		if w.Code != http.StatusForbidden || !strings.Contains(w.Body.String(), `"code":"quota_exceeded"`) || !strings.Contains(w.Body.String(), `"detail":"quota exceeded"`) {
+			t.Errorf("expect problem with code, given %d %s", w.Code, w.Body.String())
+		}
+	})
+
+	t.Run("should reject non error statuses", func(t *testing.T) {
+		t.Parallel()
+
+		defer func() {
+			if recover() == nil {
+				t.Error("expect panic on status 200")
+			}
+		}()
+
+		// method to test
+		RegisterErrorFunc(func(error) bool { return false }, ErrorMapping{Status: http.StatusOK})
+	})
+}
@@ -0,0 +1,95 @@
+package utils
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// Field is a single key-value pair attached to a log entry. Fields are rendered
+// as discrete keys instead of being concatenated into the entry message.
+type Field struct {
+	Key   string
+	Value interface{}
+	// err keeps the original error of Err and Any for routing by error type
+	err error
+}
+
+func String(key, value string) Field {
+	return Field{Key: key, Value: value}
+}
+
+func Int(key string, value int) Field {
+	return Field{Key: key, Value: value}
+}
+
+func Int64(key string, value int64) Field {
+	return Field{Key: key, Value: value}
+}
+
+func Float64(key string, value float64) Field {
+	return Field{Key: key, Value: value}
+}
+
+func Bool(key string, value bool) Field {
+	return Field{Key: key, Value: value}
+}
+
+// Duration renders value in its human-readable form e.g. 1.5s
+func Duration(key string, value time.Duration) Field {
+	return Field{Key: key, Value: value.String()}
+}
+
+func Time(key string, value time.Time) Field {
+	return Field{Key: key, Value: value}
+}
+
+// Err stores err under the "error" key. A nil error is kept as a null value.
+func Err(err error) Field {
+	if err == nil {
+		return Field{Key: "error", Value: nil}
+	}
+	return Field{Key: "error", Value: err.Error(), err: err}
+}
+
+func Any(key string, value interface{}) Field {
+	if err, ok := value.(error); ok {
+		return Field{Key: key, Value: err.Error(), err: err}
+	}
+	return Field{Key: key, Value: value}
+}
+
+// String returns the Field value the way it is displayed in plain text sinks.
+func (f Field) String() string {
+	switch v := f.Value.(type) {
+	case nil:
+		return "<nil>"
+	case string:
+		return v
+	case time.Time:
+		return v.Format(time.RFC3339)
+	case fmt.Stringer:
+		return v.String()
+	}
+	if by, err := json.Marshal(f.Value); err == nil {
+		return string(by)
+	}
+	return fmt.Sprintf("%v", f.Value)
+}
+
+// split separates Field arguments from the message parts of a log call.
+func split(variables []interface{}) ([]interface{}, []Field) {
+	var parts []interface{}
+	var fields []Field
+	for _, v := range variables {
+		switch f := v.(type) {
+		case Field:
+			fields = append(fields, f)
+		case []Field:
+			fields = append(fields, f...)
+		default:
+			parts = append(parts, v)
+		}
+	}
+	return parts, fields
+}
@@ -0,0 +1,303 @@
+package utils
+
+import (
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// backupLayout is the timestamp appended to rotated file names. It sorts
+// lexically in chronological order and contains no path separators.
+const backupLayout = "2006-01-02T15-04-05.000"
+
+type FileSinkConfig struct {
+	// Filename is the path of the active log file. Rotated files are kept in
+	// the same directory as <name>-<timestamp><ext>.
+	Filename string
+	// MaxSize in bytes the active file may grow to before rotating. 0 disables size rotation.
+	MaxSize int64
+	// Interval after which the active file is rotated. 0 disables time rotation.
+	Interval time.Duration
+	// MaxBackups is the number of rotated files to keep. 0 keeps all.
+	MaxBackups int
+	// MaxAge is how long rotated files are kept. 0 keeps all.
+	MaxAge time.Duration
+	// Compress gzips rotated files.
+	Compress bool
+	// Encoder renders entries. Defaults to JSONEncoder.
+	Encoder Encoder
+}
+
+type fileSink struct {
+	cfg      FileSinkConfig
+	mu       sync.Mutex
+	file     *os.File
+	size     int64
+	openedAt time.Time
+	closed   bool
+	// rename is os.Rename, replaced in tests to fail rotation
+	rename func(oldpath, newpath string) error
+	// bg serialises compression and retention across rotations
+	bg sync.Mutex
+	wg sync.WaitGroup
+}
+
+// FileSink writes entries to cfg.Filename, rotating it by size and/or time.
+// Compression and retention of rotated files run in the background and are
+// awaited by Close.
+func FileSink(cfg FileSinkConfig) (Sink, error) {
+	if cfg.Filename == "" {
+		return nil, errors.New("file sink: filename is required")
+	}
+	if cfg.Encoder == nil {
+		cfg.Encoder = JSONEncoder()
+	}
+	if err := os.MkdirAll(filepath.Dir(cfg.Filename), 0o755); err != nil {
+		return nil, err
+	}
+
+	s := &fileSink{cfg: cfg, rename: os.Rename}
+	if err := s.open(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+func (s *fileSink) open() error {
+	f, err := os.OpenFile(s.cfg.Filename, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return err
+	}
+	stat, err := f.Stat()
+	if err != nil {
+		_ = f.Close()
+		return err
+	}
+	s.file = f
+	s.size = stat.Size()
+	s.openedAt = time.Now()
+	return nil
+}
+
+func (s *fileSink) Write(e *Entry) error {
+	by, err := s.cfg.Encoder.Encode(e)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.closed {
+		return errors.New("file sink: closed")
+	}
+
+	// a failed rotation leaves no file open, retry before giving up the entry
+	if s.file == nil {
+		if err = s.open(); err != nil {
+			return err
+		}
+	}
+
+	if s.shouldRotate(int64(len(by))) {
+		if err = s.rotate(); err != nil {
+			return err
+		}
+	}
+
+	n, err := s.file.Write(by)
+	s.size += int64(n)
+	return err
+}
+
+func (s *fileSink) shouldRotate(next int64) bool {
+	if s.size == 0 {
+		return false
+	}
+	if s.cfg.MaxSize > 0 && s.size+next > s.cfg.MaxSize {
+		return true
+	}
+	return s.cfg.Interval > 0 && time.Since(s.openedAt) >= s.cfg.Interval
+}
+
+// rotate must be called with mu held. When the rename fails the active file
+// is reopened so a transient error does not disable the sink.
+func (s *fileSink) rotate() error {
+	err := s.file.Close()
+	s.file = nil
+	if err != nil {
+		return err
+	}
+
+	backup := s.backupName(time.Now())
+	if err = s.rename(s.cfg.Filename, backup); err != nil {
+		return errors.Join(err, s.open())
+	}
+	if err = s.open(); err != nil {
+		return err
+	}
+
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		s.bg.Lock()
+		defer s.bg.Unlock()
+		if s.cfg.Compress {
+			if err := compress(backup); err != nil {
+				fmt.Printf("%s file sink: %s\n", iCritical, err.Error())
+			}
+		}
+		s.prune()
+	}()
+	return nil
+}
+
+func (s *fileSink) split() (dir, prefix, ext string) {
+	dir = filepath.Dir(s.cfg.Filename)
+	base := filepath.Base(s.cfg.Filename)
+	ext = filepath.Ext(base)
+	return dir, strings.TrimSuffix(base, ext) + "-", ext
+}
+
+func (s *fileSink) backupName(t time.Time) string {
+	dir, prefix, ext := s.split()
+	name := filepath.Join(dir, prefix+t.Format(backupLayout)+ext)
+	for i := 1; exists(name) || exists(name+".gz"); i++ {
+		name = filepath.Join(dir, fmt.Sprintf("%s%s.%d%s", prefix, t.Format(backupLayout), i, ext))
+	}
+	return name
+}
+
+func exists(name string) bool {
+	_, err := os.Stat(name)
+	return err == nil
+}
+
+type backup struct {
+	path  string
+	time  time.Time
+	count int
+}
+
+// backups lists rotated files newest first.
+func (s *fileSink) backups() ([]backup, error) {
+	dir, prefix, ext := s.split()
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var res []backup
+	for _, en := range entries {
+		name := en.Name()
+		if en.IsDir() || !strings.HasPrefix(name, prefix) {
+			continue
+		}
+		if !strings.HasSuffix(name, ext) && !strings.HasSuffix(name, ext+".gz") {
+			continue
+		}
+		// anything after the timestamp is the collision counter and extension
+		stamp := strings.TrimPrefix(name, prefix)
+		if len(stamp) < len(backupLayout) {
+			continue
+		}
+		t, err := time.ParseInLocation(backupLayout, stamp[:len(backupLayout)], time.Local)
+		if err != nil {
+			continue
+		}
+		b := backup{path: filepath.Join(dir, name), time: t}
+		rest := strings.TrimSuffix(strings.TrimSuffix(stamp[len(backupLayout):], ".gz"), ext)
+		if strings.HasPrefix(rest, ".") {
+			b.count, _ = strconv.Atoi(rest[1:])
+		}
+		res = append(res, b)
+	}
+
+	sort.Slice(res, func(i, j int) bool {
+		if res[i].time.Equal(res[j].time) {
+			return res[i].count > res[j].count
+		}
+		return res[i].time.After(res[j].time)
+	})
+	return res, nil
+}
+
+// prune removes rotated files exceeding MaxBackups or older than MaxAge.
+func (s *fileSink) prune() {
+	if s.cfg.MaxBackups <= 0 && s.cfg.MaxAge <= 0 {
+		return
+	}
+
+	files, err := s.backups()
+	if err != nil {
+		fmt.Printf("%s file sink: %s\n", iCritical, err.Error())
+		return
+	}
+
+	for i, b := range files {
+		expired := s.cfg.MaxAge > 0 && time.Since(b.time) > s.cfg.MaxAge
+		if (s.cfg.MaxBackups > 0 && i >= s.cfg.MaxBackups) || expired {
+			if err = os.Remove(b.path); err != nil && !os.IsNotExist(err) {
+				fmt.Printf("%s file sink: %s\n", iCritical, err.Error())
+			}
+		}
+	}
+}
+
+func compress(name string) (err error) {
+	src, err := os.Open(name)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = src.Close()
+		if err == nil {
+			err = os.Remove(name)
+		}
+	}()
+
+	dst, err := os.OpenFile(name+".gz", os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o644)
+	if err != nil {
+		return err
+	}
+
+	gz := gzip.NewWriter(dst)
+	if _, err = io.Copy(gz, src); err != nil {
+		_ = gz.Close()
+		_ = dst.Close()
+		_ = os.Remove(name + ".gz")
+		return err
+	}
+	if err = gz.Close(); err != nil {
+		_ = dst.Close()
+		return err
+	}
+	return dst.Close()
+}
+
+// Close closes the active file and waits for pending compression and
+// retention work to finish.
+func (s *fileSink) Close() error {
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		return nil
+	}
+	s.closed = true
+	var err error
+	if s.file != nil {
+		err = s.file.Close()
+	}
+	s.mu.Unlock()
+
+	s.wg.Wait()
+	return err
+}
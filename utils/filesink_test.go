@@ -0,0 +1,248 @@
+package utils
+
+import (
+	"compress/gzip"
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestFileSink(t *testing.T) {
+	t.Parallel()
+
+	entry := &Entry{Id: "id", Status: iLog, Info: strings.Repeat("a", 64)}
+
+	t.Run("should write entries", func(t *testing.T) {
+		t.Parallel()
+
+		// given
+		name := filepath.Join(t.TempDir(), "app.log")
+		s, err := FileSink(FileSinkConfig{Filename: name})
+		if err != nil {
+			t.Error(err.Error())
+			t.FailNow()
+		}
+
+		lg := DevLogger("UTC", WithOutput(io.Discard), WithSink("file", s))
+
+		// method to test
+		lg.Log(context.Background(), "hello file")
+		if err = s.Close(); err != nil {
+			t.Error(err.Error())
+			t.FailNow()
+		}
+
+		// assert
+		by, err := os.ReadFile(name)
+		if err != nil {
+			t.Error(err.Error())
+			t.FailNow()
+		}
+		if !strings.Contains(string(by), `"info":"hello file"`) {
+			t.Errorf("expect entry in file, given %s", by)
+			t.FailNow()
+		}
+	})
+
+	t.Run("should rotate by size and keep max backups", func(t *testing.T) {
+		t.Parallel()
+
+		// given
+		dir := t.TempDir()
+		s, err := FileSink(FileSinkConfig{Filename: filepath.Join(dir, "app.log"), MaxSize: 150, MaxBackups: 2})
+		if err != nil {
+			t.Error(err.Error())
+			t.FailNow()
+		}
+
+		// method to test
+		for i := 0; i < 10; i++ {
+			if err = s.Write(entry); err != nil {
+				t.Error(err.Error())
+				t.FailNow()
+			}
+		}
+		if err = s.Close(); err != nil {
+			t.Error(err.Error())
+			t.FailNow()
+		}
+
+		// assert
+		files, err := os.ReadDir(dir)
+		if err != nil {
+			t.Error(err.Error())
+			t.FailNow()
+		}
+		if len(files) != 3 {
+			t.Errorf("expect active file and 2 backups, given %d files", len(files))
+			t.FailNow()
+		}
+	})
+
+	t.Run("should keep writing after a failed rotation", func(t *testing.T) {
+		t.Parallel()
+
+		// given
+		name := filepath.Join(t.TempDir(), "app.log")
+		sink, err := FileSink(FileSinkConfig{Filename: name, MaxSize: 150})
+		if err != nil {
+			t.Error(err.Error())
+			t.FailNow()
+		}
+		s := sink.(*fileSink)
+		fail := true
+		s.rename = func(oldpath, newpath string) error {
+			if fail {
+				return errors.New("device busy")
+			}
+			return os.Rename(oldpath, newpath)
+		}
+
+		// method to test
+		var failed int
+		for i := 0; i < 5; i++ {
+			if err = s.Write(entry); err != nil {
+				failed++
+			}
+		}
+		fail = false
+		if err = s.Write(entry); err != nil {
+			t.Error(err.Error())
+			t.FailNow()
+		}
+		if err = s.Close(); err != nil {
+			t.Error(err.Error())
+			t.FailNow()
+		}
+
+		// assert
+		if failed == 0 {
+			t.Error("expect rotation errors while rename fails")
+		}
+		by, err := os.ReadFile(name)
+		if err != nil || len(by) == 0 {
+			t.Errorf("expect active file written after recovery, given %d bytes %v", len(by), err)
+		}
+	})
+
+	t.Run("should rotate by time", func(t *testing.T) {
+		t.Parallel()
+
+		// given
+		dir := t.TempDir()
+		s, err := FileSink(FileSinkConfig{Filename: filepath.Join(dir, "app.log"), Interval: 10 * time.Millisecond})
+		if err != nil {
+			t.Error(err.Error())
+			t.FailNow()
+		}
+
+		// method to test
+		_ = s.Write(entry)
+		time.Sleep(20 * time.Millisecond)
+		_ = s.Write(entry)
+		if err = s.Close(); err != nil {
+			t.Error(err.Error())
+			t.FailNow()
+		}
+
+		// assert
+		files, _ := os.ReadDir(dir)
+		if len(files) != 2 {
+			t.Errorf("expect 2 files, given %d", len(files))
+			t.FailNow()
+		}
+	})
+
+	t.Run("should compress rotated files", func(t *testing.T) {
+		t.Parallel()
+
+		// given
+		dir := t.TempDir()
+		s, err := FileSink(FileSinkConfig{Filename: filepath.Join(dir, "app.log"), MaxSize: 150, Compress: true})
+		if err != nil {
+			t.Error(err.Error())
+			t.FailNow()
+		}
+
+		// method to test
+		_ = s.Write(entry)
+		_ = s.Write(entry)
+		if err = s.Close(); err != nil {
+			t.Error(err.Error())
+			t.FailNow()
+		}
+
+		// assert
+		matches, _ := filepath.Glob(filepath.Join(dir, "app-*.log.gz"))
+		if len(matches) != 1 {
+			t.Errorf("expect 1 compressed backup, given %v", matches)
+			t.FailNow()
+		}
+
+		f, err := os.Open(matches[0])
+		if err != nil {
+			t.Error(err.Error())
+			t.FailNow()
+		}
+		defer f.Close()
+
+		gz, err := gzip.NewReader(f)
+		if err != nil {
+			t.Error(err.Error())
+			t.FailNow()
+		}
+		by, _ := io.ReadAll(gz)
+		if !strings.Contains(string(by), entry.Info) {
+			t.Errorf("expect compressed entry, given %s", by)
+			t.FailNow()
+		}
+	})
+
+	t.Run("should be safe for concurrent writers", func(t *testing.T) {
+		t.Parallel()
+
+		// given
+		dir := t.TempDir()
+		name := filepath.Join(dir, "app.log")
+		s, err := FileSink(FileSinkConfig{Filename: name, MaxSize: 1024})
+		if err != nil {
+			t.Error(err.Error())
+			t.FailNow()
+		}
+
+		// method to test
+		var wg sync.WaitGroup
+		for i := 0; i < 20; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for j := 0; j < 10; j++ {
+					_ = s.Write(entry)
+				}
+			}()
+		}
+		wg.Wait()
+		if err = s.Close(); err != nil {
+			t.Error(err.Error())
+			t.FailNow()
+		}
+
+		// assert
+		files, _ := os.ReadDir(dir)
+		lines := 0
+		for _, f := range files {
+			by, _ := os.ReadFile(filepath.Join(dir, f.Name()))
+			lines += strings.Count(string(by), "\n")
+		}
+		if lines != 200 {
+			t.Errorf("expect 200 entries, given %d", lines)
+			t.FailNow()
+		}
+	})
+}
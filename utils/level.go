@@ -0,0 +1,252 @@
+package utils
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// stdout and webhook are the names of the built-in outputs in level control.
+const (
+	stdoutOutput  = "stdout"
+	webhookOutput = "webhook"
+)
+
+// ILevelController reports and changes the minimum level of every output of
+// a logger. ProdLogger and DevLogger implement it.
+type ILevelController interface {
+	Levels() map[string]LevelStatus
+	// SetLevel changes the minimum level of sink, or every output when sink is
+	// empty or *. A positive revert restores the previous level after it elapses.
+	SetLevel(sink, level string, revert time.Duration) error
+}
+
+type LevelStatus struct {
+	Level    string     `json:"level"`
+	RevertAt *time.Time `json:"revert_at,omitempty"`
+}
+
+// parseLevel is case-insensitive and accepts INFO as an alias of LOG.
+func parseLevel(level string) (logType, error) {
+	switch strings.ToUpper(strings.TrimSpace(level)) {
+	case string(iDebug):
+		return iDebug, nil
+	case string(iLog), "INFO":
+		return iLog, nil
+	case string(iError):
+		return iError, nil
+	case string(iCritical):
+		return iCritical, nil
+	case string(iFatal):
+		return iFatal, nil
+	default:
+		return "", fmt.Errorf("unknown level %q", level)
+	}
+}
+
+type levelState struct {
+	current  logType
+	baseline logType
+	timer    *time.Timer
+	revertAt time.Time
+}
+
+// levels is shared by every copy of a logger returned from With.
+type levels struct {
+	mu     sync.RWMutex
+	states map[string]*levelState
+	names  []string
+	// after schedules reverts, time.AfterFunc outside tests
+	after func(d time.Duration, f func()) *time.Timer
+}
+
+func newLevels() *levels {
+	return &levels{states: map[string]*levelState{}, after: time.AfterFunc}
+}
+
+// register adds output with the default LOG level unless already configured.
+func (l *levels) register(output string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if _, ok := l.states[output]; !ok {
+		l.states[output] = &levelState{current: iLog}
+	}
+	for _, n := range l.names {
+		if n == output {
+			return
+		}
+	}
+	l.names = append(l.names, output)
+}
+
+// enabled reports whether output accepts entries of level t.
+func (l *levels) enabled(output string, t logType) bool {
+	if l == nil {
+		return t.rank() >= iLog.rank()
+	}
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	s, ok := l.states[output]
+	if !ok {
+		return t.rank() >= iLog.rank()
+	}
+	return t.rank() >= s.current.rank()
+}
+
+// any reports whether at least one registered output accepts level t.
+func (l *levels) any(t logType) bool {
+	if l == nil {
+		return t.rank() >= iLog.rank()
+	}
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	for _, n := range l.names {
+		if t.rank() >= l.states[n].current.rank() {
+			return true
+		}
+	}
+	return false
+}
+
+// WithLevel sets the initial minimum level of an output: stdout, webhook or
+// the name given to WithSink. Every output defaults to LOG.
+func WithLevel(output, level string) Option {
+	return func(o *options) {
+		t, err := parseLevel(level)
+		if err != nil {
+			o.fail(err.Error())
+			return
+		}
+		o.levels.mu.Lock()
+		o.levels.states[output] = &levelState{current: t}
+		o.levels.mu.Unlock()
+	}
+}
+
+func (o *options) Levels() map[string]LevelStatus {
+	res := map[string]LevelStatus{}
+	if o.levels == nil {
+		return res
+	}
+	o.levels.mu.RLock()
+	defer o.levels.mu.RUnlock()
+	for _, n := range o.levels.names {
+		s := o.levels.states[n]
+		st := LevelStatus{Level: string(s.current)}
+		if s.timer != nil {
+			at := s.revertAt
+			st.RevertAt = &at
+		}
+		res[n] = st
+	}
+	return res
+}
+
+func (o *options) SetLevel(sink, level string, revert time.Duration) error {
+	t, err := parseLevel(level)
+	if err != nil {
+		return &BadRequestError{Message: err.Error()}
+	}
+	if o.levels == nil {
+		return &ServerError{Message: "level control is not available"}
+	}
+
+	l := o.levels
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	targets := l.names
+	if sink != "" && sink != "*" {
+		if _, ok := l.states[sink]; !ok {
+			return &NotFoundError{Message: fmt.Sprintf("sink %s not found", sink)}
+		}
+		targets = []string{sink}
+	}
+
+	for _, name := range targets {
+		s := l.states[name]
+		if s.timer != nil {
+			s.timer.Stop()
+		} else {
+			s.baseline = s.current
+		}
+		s.timer = nil
+		s.current = t
+
+		if revert > 0 {
+			state := s
+			var timer *time.Timer
+			s.revertAt = time.Now().Add(revert)
+			timer = l.after(revert, func() {
+				l.mu.Lock()
+				defer l.mu.Unlock()
+				// a later SetLevel replaced this revert while it was waiting for the lock
+				if state.timer != timer {
+					return
+				}
+				state.current = state.baseline
+				state.timer = nil
+			})
+			s.timer = timer
+		}
+	}
+	return nil
+}
+
+type levelRequest struct {
+	Sink        string `json:"sink"`
+	Level       string `json:"level"`
+	RevertAfter string `json:"revert_after"`
+}
+
+// LevelHandler reports (GET) and changes (PUT or POST) the minimum level of a
+// logger's outputs. authorize is called for every request; a nil authorize
+// rejects everything. Changes accept {"sink": "stdout", "level": "debug",
+// "revert_after": "10m"} where sink and revert_after are optional.
+func LevelHandler(c ILevelController, authorize func(r *http.Request) bool) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if authorize == nil || !authorize(r) {
+			ErrorResponse(w, &AccessDeniedError{})
+			return
+		}
+
+		switch r.Method {
+		case http.MethodGet:
+		case http.MethodPut, http.MethodPost:
+			var body levelRequest
+			if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1<<12)).Decode(&body); err != nil {
+				ErrorResponse(w, &BadRequestError{Message: "invalid request body"})
+				return
+			}
+
+			var revert time.Duration
+			if body.RevertAfter != "" {
+				d, err := time.ParseDuration(body.RevertAfter)
+				if err != nil || d < 0 {
+					ErrorResponse(w, &BadRequestError{Message: "invalid revert_after"})
+					return
+				}
+				revert = d
+			}
+
+			if err := c.SetLevel(body.Sink, body.Level, revert); err != nil {
+				ErrorResponse(w, err)
+				return
+			}
+		default:
+			w.Header().Set("Allow", "GET, PUT, POST")
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			_, _ = w.Write([]byte(`{"message":"method not allowed"}`))
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(map[string]interface{}{"levels": c.Levels()}); err != nil {
+			ErrorResponse(w, &ServerError{})
+		}
+	})
+}
@@ -0,0 +1,166 @@
+package utils
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLevelControl(t *testing.T) {
+	t.Parallel()
+
+	authorize := func(r *http.Request) bool {
+		return r.Header.Get("Authorization") == "secret"
+	}
+
+	request := func(h http.Handler, method, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, "/api/log-level", strings.NewReader(body))
+		req.Header.Set("Authorization", "secret")
+		rr := httptest.NewRecorder()
+		h.ServeHTTP(rr, req)
+		return rr
+	}
+
+	t.Run("sinks only receive entries at or above their level", func(t *testing.T) {
+		t.Parallel()
+
+		// given
+		sink := &memorySink{}
+		out := new(strings.Builder)
+		lg := DevLogger("UTC", WithOutput(out), WithSink("memory", sink), WithLevel("memory", "error"))
+
+		// method to test
+		lg.Debug(context.Background(), "verbose")
+		lg.Log(context.Background(), "started")
+		lg.Error(context.Background(), "failed")
+
+		// assert
+		if n := len(sink.all()); n != 1 {
+			t.Errorf("expect 1 entry in sink, given %d", n)
+		}
+		if strings.Contains(out.String(), "verbose") || !strings.Contains(out.String(), "started") {
+			t.Errorf("expect LOG and above in output, given %s", out.String())
+		}
+	})
+
+	t.Run("should report levels", func(t *testing.T) {
+		t.Parallel()
+
+		// given
+		lg := ProdLogger(time.RFC3339, "UTC", "http://localhost/webhook", WithSink("memory", &memorySink{}))
+		h := LevelHandler(lg.(ILevelController), authorize)
+
+		// method to test
+		rr := request(h, http.MethodGet, "")
+
+		// assert
+		if rr.Code != http.StatusOK {
+			t.Errorf("expect %d, given %d", http.StatusOK, rr.Code)
+			t.FailNow()
+		}
+
+		var body map[string]map[string]LevelStatus
+		if err := json.NewDecoder(rr.Body).Decode(&body); err != nil {
+			t.Error(err.Error())
+			t.FailNow()
+		}
+		for _, name := range []string{"stdout", "webhook", "memory"} {
+			if body["levels"][name].Level != "LOG" {
+				t.Errorf("expect %s at LOG, given %v", name, body["levels"])
+			}
+		}
+	})
+
+	t.Run("should change level and revert", func(t *testing.T) {
+		t.Parallel()
+
+		// given
+		out := new(strings.Builder)
+		lg := DevLogger("UTC", WithOutput(out))
+		var revert func()
+		lg.(*mockLogger).levels.after = func(d time.Duration, f func()) *time.Timer {
+			revert = f
+			// stopped so only the test reverts by calling f
+			timer := time.AfterFunc(d, func() {})
+			timer.Stop()
+			return timer
+		}
+		h := LevelHandler(lg.(ILevelController), authorize)
+
+		// method to test
+		rr := request(h, http.MethodPut, `{"sink":"stdout","level":"debug","revert_after":"10m"}`)
+
+		// assert
+		if rr.Code != http.StatusOK || !strings.Contains(rr.Body.String(), `"revert_at"`) {
+			t.Errorf("expect updated levels with revert_at, given %d %s", rr.Code, rr.Body.String())
+			t.FailNow()
+		}
+
+		lg.Debug(context.Background(), "first")
+		if revert == nil {
+			t.Errorf("expect revert to be scheduled")
+			t.FailNow()
+		}
+		revert()
+		lg.Debug(context.Background(), "second")
+
+		if !strings.Contains(out.String(), "first") || strings.Contains(out.String(), "second") {
+			t.Errorf("expect debug only before revert, given %s", out.String())
+		}
+		if lvl := lg.(ILevelController).Levels()["stdout"].Level; lvl != "LOG" {
+			t.Errorf("expect LOG after revert, given %s", lvl)
+		}
+	})
+
+	t.Run("should reject invalid requests", func(t *testing.T) {
+		t.Parallel()
+
+		// given
+		h := LevelHandler(DevLogger("UTC").(ILevelController), authorize)
+
+		cases := []struct {
+			method string
+			body   string
+			code   int
+		}{
+			{http.MethodPut, `{"level":"verbose"}`, http.StatusBadRequest},
+			{http.MethodPut, `{"sink":"unknown","level":"debug"}`, http.StatusNotFound},
+			{http.MethodPut, `{"level":"debug","revert_after":"soon"}`, http.StatusBadRequest},
+			{http.MethodDelete, ``, http.StatusMethodNotAllowed},
+		}
+
+		for _, c := range cases {
+			// method to test
+			rr := request(h, c.method, c.body)
+
+			// assert
+			if rr.Code != c.code {
+				t.Errorf("%s %s: expect %d, given %d", c.method, c.body, c.code, rr.Code)
+			}
+		}
+	})
+
+	t.Run("should reject unauthorized requests", func(t *testing.T) {
+		t.Parallel()
+
+		// given
+		lg := DevLogger("UTC").(ILevelController)
+		req := httptest.NewRequest(http.MethodPut, "/", strings.NewReader(`{"level":"debug"}`))
+		rr := httptest.NewRecorder()
+
+		// method to test
+		LevelHandler(lg, authorize).ServeHTTP(rr, req)
+
+		// assert
+		if rr.Code != http.StatusForbidden {
+			t.Errorf("expect %d, given %d", http.StatusForbidden, rr.Code)
+		}
+		if lg.Levels()["stdout"].Level != "LOG" {
+			t.Errorf("expect level unchanged, given %v", lg.Levels())
+		}
+	})
+}
@@ -10,11 +10,14 @@ import (
 	"net/http"
 	"strings"
 	"time"
+	"unicode/utf8"
 )
 
 type ILogger interface {
 	Date() time.Time
 	Timezone() *time.Location
+	With(fields ...Field) ILogger
+	Debug(ctx context.Context, variables ...interface{})
 	Error(ctx context.Context, variables ...interface{})
 	Log(ctx context.Context, variables ...interface{})
 	Fatal(variables ...interface{})
@@ -24,142 +27,386 @@ type ILogger interface {
 type logType string
 
 const (
+	iDebug    logType = "DEBUG"
 	iError    logType = "ERROR"
 	iCritical logType = "CRITICAL"
 	iLog      logType = "LOG"
 	iFatal    logType = "FATAL"
 )
 
-type discord struct {
-	Id     string  `json:"request_id,omitempty"`
-	Ip     string  `json:"ip_address,omitempty"`
-	Method string  `json:"method,omitempty"`
-	Path   string  `json:"path,omitempty"`
-	Status logType `json:"status"`
-	Time   string  `json:"time"`
-	Info   string  `json:"info"`
+// rank orders levels from least to most severe.
+func (t logType) rank() int {
+	switch t {
+	case iDebug:
+		return 1
+	case iLog:
+		return 2
+	case iError:
+		return 3
+	case iCritical:
+		return 4
+	case iFatal:
+		return 5
+	default:
+		return 0
+	}
+}
+
+// Entry is a single log event handed to every output of the logger.
+type Entry struct {
+	Id string
+	// TraceId and SpanId identify the span current when the entry was logged.
+	TraceId string
+	SpanId  string
+	Ip      string
+	Method  string
+	Path    string
+	Status  logType
+	// Time is when the entry was logged, at full precision in the logger timezone.
+	Time time.Time
+	Info string
+	// Caller is the file:line of the log call and Function its enclosing function.
+	Caller   string
+	Function string
+	// Stack is only set for ERROR and above when WithStacktrace is enabled.
+	Stack  string
+	Fields []Field
+	// errs are the errors passed to the log call, kept for routing
+	errs []error
+	// timeFormat is the layout of the logger that created the entry
+	timeFormat string
+}
+
+// reservedKeys are written by the encoders for every entry.
+var reservedKeys = map[string]bool{
+	"request_id": true, "trace_id": true, "span_id": true, "ip_address": true, "method": true, "path": true,
+	"status": true, "time": true, "info": true, "caller": true, "function": true, "stack": true,
+}
+
+// fieldKey moves a field key clashing with a built-in key under fields. so
+// the output never holds the same key twice.
+func fieldKey(key string) string {
+	if reservedKeys[key] {
+		return "fields." + key
+	}
+	return key
+}
+
+// MarshalJSON keeps the built-in keys first, in a stable order, followed by
+// every Field as its own key.
+func (e *Entry) MarshalJSON() ([]byte, error) {
+	buf := new(bytes.Buffer)
+	buf.WriteByte('{')
+	first := true
+	put := func(key string, value interface{}) error {
+		k, err := json.Marshal(key)
+		if err != nil {
+			return err
+		}
+		v, err := json.Marshal(value)
+		if err != nil {
+			v, _ = json.Marshal(fmt.Sprintf("%v", value))
+		}
+		if !first {
+			buf.WriteByte(',')
+		}
+		first = false
+		buf.Write(k)
+		buf.WriteByte(':')
+		buf.Write(v)
+		return nil
+	}
+
+	for _, kv := range []struct {
+		key   string
+		value string
+	}{
+		{"request_id", e.Id},
+		{"trace_id", e.TraceId},
+		{"span_id", e.SpanId},
+		{"ip_address", e.Ip},
+		{"method", e.Method},
+		{"path", e.Path},
+	} {
+		if kv.value == "" {
+			continue
+		}
+		if err := put(kv.key, kv.value); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := put("status", e.Status); err != nil {
+		return nil, err
+	}
+	if err := put("time", formatTime(e.Time, e.timeFormat)); err != nil {
+		return nil, err
+	}
+	if err := put("info", e.Info); err != nil {
+		return nil, err
+	}
+	for _, kv := range [][2]string{{"caller", e.Caller}, {"function", e.Function}, {"stack", e.Stack}} {
+		if kv[1] == "" {
+			continue
+		}
+		if err := put(kv[0], kv[1]); err != nil {
+			return nil, err
+		}
+	}
+	for _, f := range e.Fields {
+		if err := put(fieldKey(f.Key), f.Value); err != nil {
+			return nil, err
+		}
+	}
+
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
 }
 
 type Logger struct {
+	// TimeFormat renders timestamps when no WithTimeFormat option was given,
+	// e.g. for a Logger built as a struct literal.
 	TimeFormat string
 	TZ         *time.Location
 	Client     http.Client
 	Webhook    string
+	fields     []Field
+	options
 }
 
-func ProdLogger(timeformat, timezone, webhook string) ILogger {
+func ProdLogger(timeformat, timezone, webhook string, opts ...Option) ILogger {
 	tz, err := Timezone(timezone)
 	if err != nil {
 		log.Fatal(err.Error())
 		return nil
 	}
-	return &Logger{
+	l := &Logger{
 		TimeFormat: timeformat,
 		TZ:         tz,
 		Client:     http.Client{Timeout: 2 * time.Second},
 		Webhook:    webhook,
+		options:    newOptions(append([]Option{WithTimeFormat(timeformat)}, opts...)),
 	}
+	if err = validTimeFormat(l.timeFormat); err != nil {
+		log.Fatal(err.Error())
+		return nil
+	}
+	if webhook != "" || len(l.routes) > 0 {
+		l.levels.register(webhookOutput)
+	}
+	return l
 }
 
 func (l *Logger) Timezone() *time.Location {
 	return l.TZ
 }
 
+// With returns a copy of the logger that attaches fields to every entry.
+func (l *Logger) With(fields ...Field) ILogger {
+	c := *l
+	c.fields = append(append([]Field{}, l.fields...), fields...)
+	return &c
+}
+
+// Date returns the current time in the logger timezone at full precision.
 func (l *Logger) Date() time.Time {
-	dt, err := time.Parse(l.TimeFormat, time.Now().In(l.TZ).Format(l.TimeFormat))
-	if err != nil {
-		fmt.Print(err.Error())
-		return time.Time{}
+	return time.Now().In(l.TZ)
+}
+
+// discord caps embeds at 25 fields and 1024 characters per field value
+const (
+	maxEmbedFields     = 25
+	maxEmbedFieldValue = 1024
+)
+
+func embedValue(s string) string {
+	if len(s) > maxEmbedFieldValue {
+		// cut on a rune boundary so discord never receives invalid UTF-8
+		end := maxEmbedFieldValue - 3
+		for end > 0 && !utf8.RuneStart(s[end]) {
+			end--
+		}
+		return s[:end] + "..."
 	}
-	return dt
+	if s == "" {
+		return "-"
+	}
+	return s
 }
 
-func payload(d *discord) map[string]interface{} {
-	var title strings.Builder
-	title.WriteString("📄 New Log Entry")
-	if d.Status == iCritical || d.Status == iError {
-		title.WriteString(" @everyone")
+// payload builds the discord message for d. mentions are placed in the
+// message content as discord does not notify mentions inside embeds.
+func payload(d *Entry, mentions []string) map[string]interface{} {
+
+	fields := []map[string]string{
+		{"name": "Request ID", "value": embedValue(d.Id), "inline": "false"},
+		{"name": "IP Address", "value": embedValue(d.Ip), "inline": "false"},
+		{"name": "Method", "value": embedValue(d.Method), "inline": "false"},
+		{"name": "Path", "value": embedValue(d.Path), "inline": "false"},
+		{"name": "Time", "value": embedValue(d.Timestamp()), "inline": "false"},
+		{"name": "Info", "value": embedValue(d.Info), "inline": "false"},
+	}
+	if d.TraceId != "" {
+		fields = append(fields, map[string]string{"name": "Trace ID", "value": embedValue(d.TraceId), "inline": "false"})
+	}
+	if d.Caller != "" {
+		fields = append(fields, map[string]string{"name": "Caller", "value": embedValue(d.Caller + " " + d.Function), "inline": "false"})
+	}
+	if d.Stack != "" {
+		fields = append(fields, map[string]string{"name": "Stack", "value": embedValue("```" + d.Stack + "```"), "inline": "false"})
+	}
+	for _, f := range d.Fields {
+		if len(fields) == maxEmbedFields {
+			break
+		}
+		fields = append(fields, map[string]string{"name": f.Key, "value": embedValue(f.String()), "inline": "true"})
 	}
 
-	return map[string]interface{}{
+	p := map[string]interface{}{
 		"embeds": []map[string]interface{}{
 			{
-				"title":       title.String(),
+				"title":       "📄 New Log Entry",
 				"description": fmt.Sprintf("Status: %s", d.Status),
 				"color":       5814783, // color
-				"fields": []map[string]string{
-					{"name": "Request ID", "value": d.Id, "inline": "false"},
-					{"name": "IP Address", "value": d.Ip, "inline": "false"},
-					{"name": "Method", "value": d.Method, "inline": "false"},
-					{"name": "Path", "value": d.Path, "inline": "false"},
-					{"name": "Time", "value": d.Time, "inline": "false"},
-					{"name": "Info", "value": d.Info, "inline": "false"},
-				},
+				"fields":      fields,
 			},
 		},
 	}
+	if len(mentions) > 0 {
+		p["content"] = strings.Join(mentions, " ")
+		p["allowed_mentions"] = map[string][]string{"parse": {"everyone", "roles", "users"}}
+	}
+	return p
 }
 
+// Emit posts p to the logger webhook.
 func (l *Logger) Emit(p interface{}) {
+	l.emitTo(l.Webhook, p)
+}
+
+func (l *Logger) emitTo(webhook string, p interface{}) {
 	buf := new(bytes.Buffer)
 	if err := json.NewEncoder(buf).Encode(p); err != nil {
-		fmt.Printf("%s %s", iCritical, err.Error())
+		fmt.Printf("%s %s\n", iCritical, err.Error())
+		return
+	}
+
+	res, err := l.Client.Post(webhook, "application/json", buf)
+	if err != nil {
+		fmt.Printf("%s %s\n", iCritical, err.Error())
 		return
 	}
+	_ = res.Body.Close()
+	if res.StatusCode >= http.StatusMultipleChoices {
+		fmt.Printf("%s webhook responded with status %d\n", iCritical, res.StatusCode)
+	}
+}
 
-	if _, err := http.Post(l.Webhook, "application/json", buf); err != nil {
-		fmt.Printf("%s %s", iCritical, err.Error())
+func (l *Logger) write(e *Entry) {
+	// not in logformat, a wrapper frame would shift the caller it captures
+	if e.timeFormat == "" {
+		e.timeFormat = l.TimeFormat
+	}
+	if !l.sample(e) {
+		return
+	}
+	forward := l.allow(e, l.Date, l.emit)
+	l.print(e)
+	if forward {
+		l.emit(e)
 	}
 }
 
+// emit sends e to the routed webhooks and every registered sink.
+func (l *Logger) emit(e *Entry) {
+	if l.levels.enabled(webhookOutput, e.Status) {
+		for _, d := range l.route(e, l.Webhook, l.TZ, time.Now()) {
+			l.emitTo(d.webhook, payload(e, d.mentions))
+		}
+	}
+	l.dispatch(e)
+}
+
+func (l *Logger) Debug(ctx context.Context, variables ...interface{}) {
+	if !l.levels.any(iDebug) {
+		return
+	}
+	l.write(l.logformat(ctx, iDebug, l.Date(), l.fields, variables...))
+}
+
 func (l *Logger) Error(ctx context.Context, variables ...interface{}) {
-	d, str, err := logformat(ctx, iError, l.Date(), variables)
-	if err != nil {
-		fmt.Print(err.Error())
+	if !l.levels.any(iError) {
 		return
 	}
-	fmt.Print(str)
-	l.Emit(payload(d))
+	l.write(l.logformat(ctx, iError, l.Date(), l.fields, variables...))
 }
 
 func (l *Logger) Critical(ctx context.Context, variables ...interface{}) {
-	d, str, err := logformat(ctx, iCritical, l.Date(), variables)
-	if err != nil {
-		fmt.Print(err.Error())
+	if !l.levels.any(iCritical) {
 		return
 	}
-	fmt.Print(str)
-	l.Emit(payload(d))
+	l.write(l.logformat(ctx, iCritical, l.Date(), l.fields, variables...))
 }
 
 func (l *Logger) Log(ctx context.Context, variables ...interface{}) {
-	d, str, err := logformat(ctx, iLog, l.Date(), variables)
-	if err != nil {
-		fmt.Print(err.Error())
+	if !l.levels.any(iLog) {
 		return
 	}
-	fmt.Print(str)
-	l.Emit(payload(d))
+	l.write(l.logformat(ctx, iLog, l.Date(), l.fields, variables...))
 }
 
 func (l *Logger) Fatal(variables ...interface{}) {
-	_, str, err := logformat(context.Background(), iFatal, l.Date(), variables)
-	if err != nil {
-		log.Fatal(err.Error())
-	}
-	log.Fatal(str)
+	e := l.logformat(context.Background(), iFatal, l.Date(), l.fields, variables...)
+	l.fatal(func() { l.write(e) })
 }
 
-func logformat(ctx context.Context, status logType, d time.Time, variables ...interface{}) (*discord, string, error) {
+// logformat builds the Entry for a log call. Field arguments become discrete
+// keys, every other argument is joined with a space into the message. When
+// redaction is enabled it is applied here so no output sees the raw values.
+func (opt *options) logformat(ctx context.Context, status logType, d time.Time, fields []Field, variables ...interface{}) *Entry {
+	parts, extra := split(variables)
+	r := opt.redactor
 	var sb strings.Builder
-	for _, v := range variables {
-		sb.WriteString(fmt.Sprintf("%v", v))
+	var errs []error
+	for i, v := range parts {
+		if err, ok := v.(error); ok {
+			errs = append(errs, err)
+		}
+		if i > 0 {
+			sb.WriteString(" ")
+		}
+		if r != nil {
+			sb.WriteString(r.part(v))
+		} else {
+			sb.WriteString(fmt.Sprintf("%v", v))
+		}
+	}
+
+	o := Entry{
+		Status:     status,
+		Time:       d,
+		Info:       sb.String(),
+		Fields:     append(append([]Field{}, fields...), extra...),
+		timeFormat: opt.timeFormat,
 	}
+	for _, f := range o.Fields {
+		if f.err != nil {
+			errs = append(errs, f.err)
+		}
+	}
+	o.errs = errs
 
-	o := discord{
-		Status: status,
-		Time:   d.Format(time.RFC822),
-		Info:   sb.String(),
+	// skip logformat and the ILogger method that called it
+	depth := 1
+	if opt.stacktrace && status.rank() >= iError.rank() {
+		depth = maxStackFrames
+	}
+	if frames := callsite(2+opt.callerSkip, depth); len(frames) > 0 {
+		o.Caller = fmt.Sprintf("%s:%d", shortPath(frames[0].File), frames[0].Line)
+		o.Function = frames[0].Function
+		if depth > 1 {
+			o.Stack = stack(frames)
+		}
 	}
 
 	obj, ok := ctx.Value(RequestKey).(*RequestBody)
@@ -170,12 +417,21 @@ func logformat(ctx context.Context, status logType, d time.Time, variables ...in
 		o.Ip = obj.Ip
 		o.Method = obj.Method
 		o.Path = obj.Path
+		o.TraceId = obj.TraceId
+		o.SpanId = obj.SpanId
+	}
+	// spans started inside the request are more specific than the request span
+	if s := SpanFromContext(ctx); s != nil && s.sc.IsValid() {
+		o.TraceId = s.sc.TraceID.String()
+		o.SpanId = s.sc.SpanID.String()
 	}
 
-	js, err := json.MarshalIndent(o, "", "  ")
-	if err != nil {
-		return nil, "", err
+	if r != nil {
+		o.Path = r.text(o.Path)
+		for i, f := range o.Fields {
+			o.Fields[i] = r.field(f)
+		}
 	}
 
-	return &o, string(js) + "\n", nil
+	return &o
 }
@@ -0,0 +1,526 @@
+package utils
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf8"
+)
+
+func TestLogFormat(t *testing.T) {
+	t.Parallel()
+
+	t.Run("fields are discrete keys", func(t *testing.T) {
+		t.Parallel()
+
+		// given
+		ctx := context.WithValue(context.Background(), RequestKey, &RequestBody{Id: "id", Method: "GET", Path: "/"})
+
+		// method to test
+		e := (&options{}).logformat(ctx, iError, time.Now(), []Field{String("service", "api")}, "insert failed", Int("attempt", 2), Err(errors.New("boom")))
+
+		// assert
+		if e.Info != "insert failed" {
+			t.Errorf("expect insert failed, given %s", e.Info)
+			t.FailNow()
+		}
+
+		by, err := JSONEncoder().Encode(e)
+		if err != nil {
+			t.Error(err.Error())
+			t.FailNow()
+		}
+
+		var m map[string]interface{}
+		if err = json.Unmarshal(by, &m); err != nil {
+			t.Error(err.Error())
+			t.FailNow()
+		}
+
+		expect := map[string]interface{}{"request_id": "id", "service": "api", "attempt": float64(2), "error": "boom", "status": "ERROR"}
+		for k, v := range expect {
+			if m[k] != v {
+				t.Errorf("expect %s to equal %v, given %v", k, v, m[k])
+			}
+		}
+	})
+
+	t.Run("message parts are separated", func(t *testing.T) {
+		t.Parallel()
+
+		// method to test
+		e := (&options{}).logformat(context.Background(), iLog, time.Now(), nil, "first", 2, errors.New("third"))
+
+		// assert
+		if e.Info != "first 2 third" {
+			t.Errorf("expect first 2 third, given %s", e.Info)
+			t.FailNow()
+		}
+	})
+
+	t.Run("built-in keys come first", func(t *testing.T) {
+		t.Parallel()
+
+		// given
+		e := &Entry{Id: "id", Status: iLog, Time: time.Date(2026, 10, 19, 8, 30, 0, 123456789, time.UTC), Info: "info", Fields: []Field{Bool("ok", true)}}
+
+		// method to test
+		by, err := json.Marshal(e)
+		if err != nil {
+			t.Error(err.Error())
+			t.FailNow()
+		}
+
+		// assert
+		s := `{"request_id":"id","status":"LOG","time":"2026-10-19T08:30:00.123456789Z","info":"info","ok":true}`
+		if strings.TrimSpace(string(by)) != s {
+			t.Errorf("expect %s, given %s", s, by)
+			t.FailNow()
+		}
+	})
+
+	t.Run("with appends fields without mutating parent", func(t *testing.T) {
+		t.Parallel()
+
+		// given
+		parent := DevLogger("UTC").(*mockLogger)
+
+		// method to test
+		child := parent.With(String("a", "b")).(*mockLogger)
+		child.With(String("c", "d"))
+
+		// assert
+		if len(parent.fields) != 0 {
+			t.Errorf("expect parent to have no fields, given %v", parent.fields)
+		}
+		if len(child.fields) != 1 {
+			t.Errorf("expect child to have 1 field, given %v", child.fields)
+		}
+	})
+}
+
+func TestEncoder(t *testing.T) {
+	t.Parallel()
+
+	e := &Entry{
+		Id:     "id",
+		Method: "GET",
+		Path:   "/api",
+		Status: iError,
+		Time:   time.Date(2026, 10, 19, 8, 30, 0, 0, time.UTC),
+		Info:   "insert failed",
+		Fields: []Field{Int("attempt", 2), String("reason", "duplicate key")},
+		// entries carry the format of the logger that created them
+		timeFormat: "15:04:05",
+	}
+
+	t.Run("json lines", func(t *testing.T) {
+		t.Parallel()
+
+		// method to test
+		by, err := JSONEncoder().Encode(e)
+		if err != nil {
+			t.Error(err.Error())
+			t.FailNow()
+		}
+
+		// assert
+		str := string(by)
+		if strings.Count(str, "\n") != 1 || !strings.HasSuffix(str, "\n") {
+			t.Errorf("expect a single line, given %q", str)
+			t.FailNow()
+		}
+	})
+
+	t.Run("logfmt", func(t *testing.T) {
+		t.Parallel()
+
+		// method to test
+		by, err := LogfmtEncoder().Encode(e)
+		if err != nil {
+			t.Error(err.Error())
+			t.FailNow()
+		}
+
+		// assert
+		s := `time=08:30:00 status=ERROR request_id=id method=GET path=/api info="insert failed" attempt=2 reason="duplicate key"` + "\n"
+		if string(by) != s {
+			t.Errorf("expect %q, given %q", s, by)
+			t.FailNow()
+		}
+	})
+
+	t.Run("should namespace fields clashing with built-in keys", func(t *testing.T) {
+		t.Parallel()
+
+		// given
+		clash := *e
+		clash.Fields = []Field{Int("status", 200), String("time", "1.5s")}
+
+		// method to test
+		js, err := JSONEncoder().Encode(&clash)
+		if err != nil {
+			t.Error(err.Error())
+			t.FailNow()
+		}
+		lf, err := LogfmtEncoder().Encode(&clash)
+		if err != nil {
+			t.Error(err.Error())
+			t.FailNow()
+		}
+
+		// assert
+		if strings.Count(string(js), `"status":`) != 1 || !strings.Contains(string(js), `"status":"ERROR"`) || !strings.Contains(string(js), `"fields.status":200`) || !strings.Contains(string(js), `"fields.time":"1.5s"`) {
+			t.Errorf("expect namespaced fields, given %s", js)
+		}
+		if strings.Count(string(lf), " status=") != 1 || !strings.Contains(string(lf), "fields.status=200") || !strings.Contains(string(lf), "fields.time=1.5s") {
+			t.Errorf("expect namespaced fields, given %s", lf)
+		}
+	})
+
+	t.Run("console without color", func(t *testing.T) {
+		t.Parallel()
+
+		// method to test
+		by, err := ConsoleEncoder(false).Encode(e)
+		if err != nil {
+			t.Error(err.Error())
+			t.FailNow()
+		}
+
+		// assert
+		s := `08:30:00 ERROR   GET /api insert failed request_id=id attempt=2 reason="duplicate key"` + "\n"
+		if string(by) != s {
+			t.Errorf("expect %q, given %q", s, by)
+			t.FailNow()
+		}
+	})
+
+	t.Run("console separates critical level", func(t *testing.T) {
+		t.Parallel()
+
+		// given
+		critical := *e
+		critical.Status = iCritical
+
+		// method to test
+		by, err := ConsoleEncoder(false).Encode(&critical)
+		if err != nil {
+			t.Error(err.Error())
+			t.FailNow()
+		}
+
+		// assert
+		s := `08:30:00 CRITICAL GET /api insert failed request_id=id attempt=2 reason="duplicate key"` + "\n"
+		if string(by) != s {
+			t.Errorf("expect %q, given %q", s, by)
+			t.FailNow()
+		}
+	})
+
+	t.Run("console with color", func(t *testing.T) {
+		t.Parallel()
+
+		// method to test
+		by, err := ConsoleEncoder(true).Encode(e)
+		if err != nil {
+			t.Error(err.Error())
+			t.FailNow()
+		}
+
+		// assert
+		if !strings.Contains(string(by), colorRed) {
+			t.Errorf("expect level to be colored, given %q", by)
+			t.FailNow()
+		}
+	})
+
+	t.Run("logger writes with configured encoder", func(t *testing.T) {
+		t.Parallel()
+
+		// given
+		buf := new(strings.Builder)
+		lg := DevLogger("UTC", WithEncoder(LogfmtEncoder()), WithOutput(buf))
+
+		// method to test
+		lg.Log(context.Background(), "hello")
+
+		// assert
+		if !strings.Contains(buf.String(), "info=hello") {
+			t.Errorf("expect logfmt output, given %q", buf.String())
+			t.FailNow()
+		}
+	})
+}
+
+// logHelper wraps a logger the way application helpers do.
+func logHelper(lg ILogger, message string) {
+	lg.Error(context.Background(), message)
+}
+
+func TestCaller(t *testing.T) {
+	t.Parallel()
+
+	t.Run("should capture caller and function", func(t *testing.T) {
+		t.Parallel()
+
+		// given
+		sink := &memorySink{}
+		lg := DevLogger("UTC", WithOutput(io.Discard), WithSink("memory", sink))
+
+		// method to test
+		lg.Log(context.Background(), "hello")
+
+		// assert
+		e := sink.all()[0]
+		if !strings.HasPrefix(e.Caller, "utils/log_test.go:") {
+			t.Errorf("expect caller in log_test.go, given %s", e.Caller)
+		}
+		if !strings.Contains(e.Function, "TestCaller") {
+			t.Errorf("expect function TestCaller, given %s", e.Function)
+		}
+		if e.Stack != "" {
+			t.Errorf("expect no stack, given %s", e.Stack)
+		}
+	})
+
+	t.Run("should capture caller of production logger", func(t *testing.T) {
+		t.Parallel()
+
+		// given
+		sink := &memorySink{}
+		lg := ProdLogger(time.RFC3339, "UTC", "", WithOutput(io.Discard), WithSink("memory", sink), WithStacktrace())
+
+		// method to test
+		lg.Error(context.Background(), "hello")
+
+		// assert
+		e := sink.all()[0]
+		if !strings.HasPrefix(e.Caller, "utils/log_test.go:") || !strings.Contains(e.Function, "TestCaller") {
+			t.Errorf("expect caller in TestCaller, given %s %s", e.Caller, e.Function)
+		}
+		if strings.Contains(e.Stack, "utils/log.go") {
+			t.Errorf("expect stack to start at the caller, given %s", e.Stack)
+		}
+	})
+
+	t.Run("should skip helper frames", func(t *testing.T) {
+		t.Parallel()
+
+		// given
+		sink := &memorySink{}
+		lg := DevLogger("UTC", WithOutput(io.Discard), WithSink("memory", sink), WithCallerSkip(1))
+
+		// method to test
+		logHelper(lg, "hello")
+
+		// assert
+		if f := sink.all()[0].Function; !strings.Contains(f, "TestCaller") {
+			t.Errorf("expect function TestCaller, given %s", f)
+		}
+	})
+
+	t.Run("should add caller skip to the recording logger frame", func(t *testing.T) {
+		t.Parallel()
+
+		// given
+		lg := RecordingLogger(WithCallerSkip(1))
+
+		// method to test
+		logHelper(lg, "hello")
+
+		// assert
+		if f := lg.Entries()[0].Function; !strings.Contains(f, "TestCaller") {
+			t.Errorf("expect function TestCaller, given %s", f)
+		}
+	})
+
+	t.Run("should attach stack to errors only", func(t *testing.T) {
+		t.Parallel()
+
+		// given
+		sink := &memorySink{}
+		lg := DevLogger("UTC", WithOutput(io.Discard), WithSink("memory", sink), WithStacktrace())
+
+		// method to test
+		lg.Log(context.Background(), "hello")
+		lg.Error(context.Background(), "failed")
+
+		// assert
+		entries := sink.all()
+		if entries[0].Stack != "" {
+			t.Errorf("expect no stack for LOG, given %s", entries[0].Stack)
+		}
+		if !strings.Contains(entries[1].Stack, "TestCaller") || strings.Contains(entries[1].Stack, "logformat") {
+			t.Errorf("expect trimmed stack starting at caller, given %s", entries[1].Stack)
+		}
+	})
+}
+
+func TestRecorder(t *testing.T) {
+	t.Parallel()
+
+	// given
+	lg := RecordingLogger()
+	ctx := context.WithValue(context.Background(), RequestKey, &RequestBody{Id: "req-1", Path: "/api"})
+
+	// method to test
+	lg.With(String("service", "api")).Error(ctx, "insert failed")
+	lg.Log(context.Background(), "started")
+	lg.Fatal("shutting down")
+
+	// assert
+	lg.AssertCount(t, Query{}, 3)
+	lg.AssertLogged(t, Query{Level: "ERROR", RequestId: "req-1", Path: "/api", Field: "service"})
+	lg.AssertLogged(t, Query{Level: "FATAL", Message: "shutting down"})
+	lg.AssertNotLogged(t, Query{Level: "CRITICAL"})
+
+	for _, e := range lg.Entries() {
+		if !strings.HasPrefix(e.Caller, "utils/log_test.go:") {
+			t.Errorf("expect caller in log_test.go, given %s", e.Caller)
+		}
+	}
+
+	lg.Reset()
+	lg.AssertCount(t, Query{}, 0)
+}
+
+func TestTimeFormat(t *testing.T) {
+	t.Parallel()
+
+	t.Run("struct literal logger uses TimeFormat", func(t *testing.T) {
+		t.Parallel()
+
+		// given
+		out := new(strings.Builder)
+		lg := &Logger{TZ: time.UTC, TimeFormat: time.RFC3339}
+		WithOutput(out)(&lg.options)
+
+		// method to test
+		lg.Log(context.Background(), "literal")
+
+		// assert
+		var body map[string]interface{}
+		if err := json.Unmarshal([]byte(out.String()), &body); err != nil {
+			t.Error(err.Error())
+			t.FailNow()
+		}
+		if _, err := time.Parse(time.RFC3339, body["time"].(string)); err != nil || strings.Contains(body["time"].(string), ".") {
+			t.Errorf("expect RFC3339 without fraction, given %s", body["time"])
+		}
+	})
+
+	t.Run("entries keep full precision in the logger timezone", func(t *testing.T) {
+		t.Parallel()
+
+		// given
+		out := new(strings.Builder)
+		lg := DevLogger("America/New_York", WithOutput(out))
+
+		// method to test
+		lg.Log(context.Background(), "precise")
+
+		// assert
+		var body map[string]interface{}
+		if err := json.Unmarshal([]byte(out.String()), &body); err != nil {
+			t.Error(err.Error())
+			t.FailNow()
+		}
+		ts, err := time.Parse(time.RFC3339Nano, body["time"].(string))
+		if err != nil {
+			t.Error(err.Error())
+			t.FailNow()
+		}
+		if ts.Nanosecond() == 0 {
+			t.Errorf("expect sub-second precision, given %s", body["time"])
+		}
+		if _, offset := ts.Zone(); offset != -4*3600 && offset != -5*3600 {
+			t.Errorf("expect New York offset, given %s", body["time"])
+		}
+	})
+
+	t.Run("encoders override the logger format", func(t *testing.T) {
+		t.Parallel()
+
+		// given
+		e := &Entry{Status: iLog, Time: time.UnixMilli(1760862600123), Info: "info", timeFormat: time.RFC822}
+
+		// method to test
+		by, err := JSONEncoder(EncodeTime(UnixMillis)).Encode(e)
+		if err != nil {
+			t.Error(err.Error())
+			t.FailNow()
+		}
+		line, err := LogfmtEncoder(EncodeTime(time.RFC3339Nano)).Encode(e)
+		if err != nil {
+			t.Error(err.Error())
+			t.FailNow()
+		}
+
+		// assert
+		if !strings.Contains(string(by), `"time":1760862600123`) {
+			t.Errorf("expect unix millis, given %s", by)
+		}
+		if !strings.Contains(string(line), "time=2025-10-19T") || !strings.Contains(string(line), ".123") {
+			t.Errorf("expect RFC3339Nano, given %s", line)
+		}
+		if e.timeFormat != time.RFC822 {
+			t.Errorf("expect entry to be unchanged, given %s", e.timeFormat)
+		}
+	})
+
+	t.Run("validates layouts", func(t *testing.T) {
+		t.Parallel()
+
+		for layout, valid := range map[string]bool{
+			"":                 true,
+			UnixMillis:         true,
+			time.RFC3339Nano:   true,
+			"2006-01-02 15:04": true,
+			"timestamp":        false,
+		} {
+			// method to test
+			err := validTimeFormat(layout)
+
+			// assert
+			if (err == nil) != valid {
+				t.Errorf("%q: expect valid %v, given %v", layout, valid, err)
+			}
+		}
+	})
+}
+
+func TestEmbedValue(t *testing.T) {
+	t.Parallel()
+
+	t.Run("should truncate on a rune boundary", func(t *testing.T) {
+		t.Parallel()
+
+		// given
+		s := strings.Repeat("é", maxEmbedFieldValue)
+
+		// method to test
+		v := embedValue(s)
+
+		// assert
+		if !utf8.ValidString(v) || len(v) > maxEmbedFieldValue || !strings.HasSuffix(v, "é...") {
+			t.Errorf("expect valid UTF-8 of at most %d bytes, given %d bytes %q", maxEmbedFieldValue, len(v), v[len(v)-8:])
+		}
+	})
+
+	t.Run("should keep short values", func(t *testing.T) {
+		t.Parallel()
+
+		// method to test
+		v := embedValue("naïve café")
+
+		// assert
+		if v != "naïve café" {
+			t.Errorf("expect value unchanged, given %q", v)
+		}
+	})
+}
@@ -0,0 +1,130 @@
+package utils
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type LokiConfig struct {
+	// URL of the Loki server e.g. http://localhost:3100
+	URL string
+	// Service is added as the service label.
+	Service string
+	// Labels are static labels added to every stream.
+	Labels map[string]string
+	// DisablePathLabel drops the request path label for high cardinality routes.
+	DisablePathLabel bool
+	// Headers are added to every push request e.g. X-Scope-OrgID or Authorization.
+	Headers map[string]string
+	// Client defaults to an http.Client with a 5 second timeout.
+	Client *http.Client
+	Batch  BatchConfig
+}
+
+type lokiSink struct {
+	cfg     LokiConfig
+	url     string
+	batcher *batcher
+}
+
+// LokiSink ships entries to the Loki push API. Streams are labelled by level,
+// service and path and each line is the JSON encoded entry.
+func LokiSink(cfg LokiConfig) (Sink, error) {
+	if cfg.URL == "" {
+		return nil, errors.New("loki sink: url is required")
+	}
+	if cfg.Client == nil {
+		cfg.Client = &http.Client{Timeout: 5 * time.Second}
+	}
+
+	s := &lokiSink{cfg: cfg, url: strings.TrimSuffix(cfg.URL, "/") + "/loki/api/v1/push"}
+	s.batcher = newBatcher("loki sink", cfg.Batch, s.push)
+	return s, nil
+}
+
+func (s *lokiSink) Write(e *Entry) error {
+	return s.batcher.add(e)
+}
+
+func (s *lokiSink) Close() error {
+	s.batcher.close()
+	return nil
+}
+
+func (s *lokiSink) labels(e *Entry) map[string]string {
+	labels := map[string]string{"level": strings.ToLower(string(e.Status))}
+	for k, v := range s.cfg.Labels {
+		labels[k] = v
+	}
+	if s.cfg.Service != "" {
+		labels["service"] = s.cfg.Service
+	}
+	if e.Path != "" && !s.cfg.DisablePathLabel {
+		labels["path"] = e.Path
+	}
+	return labels
+}
+
+// streamKey identifies a label set independent of map ordering.
+func streamKey(labels map[string]string) string {
+	keys := make([]string, 0, len(labels))
+	for k := range labels {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	var sb strings.Builder
+	for _, k := range keys {
+		sb.WriteString(k + "=" + labels[k] + ",")
+	}
+	return sb.String()
+}
+
+type lokiStream struct {
+	Stream map[string]string `json:"stream"`
+	Values [][2]string       `json:"values"`
+}
+
+func (s *lokiSink) push(batch []queued) ([]queued, int, error) {
+	var streams []*lokiStream
+	var encodeErr error
+	encoded := make([]queued, 0, len(batch))
+	index := map[string]*lokiStream{}
+	for _, q := range batch {
+		line, err := json.Marshal(q.entry)
+		if err != nil {
+			encodeErr = err
+			continue
+		}
+		encoded = append(encoded, q)
+		labels := s.labels(q.entry)
+		key := streamKey(labels)
+		st, ok := index[key]
+		if !ok {
+			st = &lokiStream{Stream: labels}
+			index[key] = st
+			streams = append(streams, st)
+		}
+		st.Values = append(st.Values, [2]string{strconv.FormatInt(q.at.UnixNano(), 10), string(line)})
+	}
+
+	unencoded := len(batch) - len(encoded)
+	body, err := json.Marshal(map[string]interface{}{"streams": streams})
+	if err != nil {
+		return nil, len(batch), err
+	}
+
+	if _, err = post(s.cfg.Client, s.url, "application/json", s.cfg.Headers, bytes.NewReader(body)); err != nil {
+		var se *statusError
+		if errors.As(err, &se) && !retryable(se.code) {
+			return nil, len(batch), err
+		}
+		return encoded, unencoded, err
+	}
+	return nil, unencoded, encodeErr
+}
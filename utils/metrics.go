@@ -0,0 +1,267 @@
+package utils
+
+import (
+	"bufio"
+	"fmt"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// DefBuckets are the Prometheus default latency buckets in seconds.
+var DefBuckets = []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+
+// DefaultRegistry collects the metrics of this module: request metrics from
+// the middleware, cache metrics and log sink queue metrics.
+var DefaultRegistry = MetricsRegistry()
+
+type metricKind string
+
+const (
+	kindCounter   metricKind = "counter"
+	kindGauge     metricKind = "gauge"
+	kindHistogram metricKind = "histogram"
+)
+
+// Registry holds metrics in registration order and renders them in the
+// Prometheus text exposition format.
+type Registry struct {
+	mu      sync.Mutex
+	metrics []*metric
+	byName  map[string]*metric
+}
+
+// MetricsRegistry returns an empty Registry.
+func MetricsRegistry() *Registry {
+	return &Registry{byName: map[string]*metric{}}
+}
+
+type metric struct {
+	name    string
+	help    string
+	kind    metricKind
+	labels  []string
+	buckets []float64
+	fn      func() float64
+	mu      sync.Mutex
+	series  map[string]*series
+}
+
+type series struct {
+	values []string
+	value  float64
+	// counts per bucket, only for histograms
+	counts []uint64
+	count  uint64
+}
+
+// register returns the metric called name, creating it on first use so
+// independent callers can share a metric. Registering the same name with a
+// different type or labels is a programming error and panics.
+func (r *Registry) register(name, help string, kind metricKind, labels []string, buckets []float64) *metric {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if m, ok := r.byName[name]; ok {
+		if m.kind != kind || strings.Join(m.labels, ",") != strings.Join(labels, ",") {
+			panic(fmt.Sprintf("metrics: %s already registered as %s%v", name, m.kind, m.labels))
+		}
+		return m
+	}
+	m := &metric{name: name, help: help, kind: kind, labels: labels, buckets: buckets, series: map[string]*series{}}
+	r.metrics = append(r.metrics, m)
+	r.byName[name] = m
+	return m
+}
+
+func (m *metric) get(values []string) *series {
+	if len(values) != len(m.labels) {
+		panic(fmt.Sprintf("metrics: %s expects %d label values, given %d", m.name, len(m.labels), len(values)))
+	}
+	key := strings.Join(values, "\xff")
+	s, ok := m.series[key]
+	if !ok {
+		s = &series{values: append([]string{}, values...)}
+		if m.kind == kindHistogram {
+			s.counts = make([]uint64, len(m.buckets))
+		}
+		m.series[key] = s
+	}
+	return s
+}
+
+// CounterVec is a monotonically increasing value per label combination.
+type CounterVec struct{ m *metric }
+
+func (r *Registry) Counter(name, help string, labels ...string) *CounterVec {
+	return &CounterVec{m: r.register(name, help, kindCounter, labels, nil)}
+}
+
+func (c *CounterVec) Inc(values ...string) {
+	c.Add(1, values...)
+}
+
+// Add increases the counter, negative values are ignored.
+func (c *CounterVec) Add(v float64, values ...string) {
+	if v < 0 {
+		return
+	}
+	c.m.mu.Lock()
+	defer c.m.mu.Unlock()
+	c.m.get(values).value += v
+}
+
+// GaugeVec is a value per label combination that can go up and down.
+type GaugeVec struct{ m *metric }
+
+func (r *Registry) Gauge(name, help string, labels ...string) *GaugeVec {
+	return &GaugeVec{m: r.register(name, help, kindGauge, labels, nil)}
+}
+
+func (g *GaugeVec) Set(v float64, values ...string) {
+	g.m.mu.Lock()
+	defer g.m.mu.Unlock()
+	g.m.get(values).value = v
+}
+
+func (g *GaugeVec) Add(v float64, values ...string) {
+	g.m.mu.Lock()
+	defer g.m.mu.Unlock()
+	g.m.get(values).value += v
+}
+
+func (g *GaugeVec) Inc(values ...string) { g.Add(1, values...) }
+func (g *GaugeVec) Dec(values ...string) { g.Add(-1, values...) }
+
+// GaugeFunc registers a gauge without labels read from fn on every scrape.
+func (r *Registry) GaugeFunc(name, help string, fn func() float64) {
+	m := r.register(name, help, kindGauge, nil, nil)
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.fn = fn
+}
+
+// HistogramVec counts observations into cumulative buckets per label combination.
+type HistogramVec struct{ m *metric }
+
+// Histogram registers a histogram. buckets are upper bounds in increasing
+// order and default to DefBuckets.
+func (r *Registry) Histogram(name, help string, buckets []float64, labels ...string) *HistogramVec {
+	if len(buckets) == 0 {
+		buckets = DefBuckets
+	}
+	buckets = append([]float64{}, buckets...)
+	sort.Float64s(buckets)
+	return &HistogramVec{m: r.register(name, help, kindHistogram, labels, buckets)}
+}
+
+func (h *HistogramVec) Observe(v float64, values ...string) {
+	h.m.mu.Lock()
+	defer h.m.mu.Unlock()
+	s := h.m.get(values)
+	for i, b := range h.m.buckets {
+		if v <= b {
+			s.counts[i]++
+		}
+	}
+	s.count++
+	s.value += v
+}
+
+// MetricsHandler serves reg in the Prometheus text exposition format 0.0.4.
+func MetricsHandler(reg *Registry) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			w.Header().Set("Allow", "GET, HEAD")
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			_, _ = w.Write([]byte(`{"message":"method not allowed"}`))
+			return
+		}
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		if r.Method == http.MethodHead {
+			return
+		}
+		bw := bufio.NewWriter(w)
+		reg.write(bw)
+		_ = bw.Flush()
+	})
+}
+
+func (r *Registry) write(w *bufio.Writer) {
+	r.mu.Lock()
+	metrics := append([]*metric{}, r.metrics...)
+	r.mu.Unlock()
+
+	for _, m := range metrics {
+		m.mu.Lock()
+		fmt.Fprintf(w, "# HELP %s %s\n", m.name, strings.NewReplacer(`\`, `\\`, "\n", `\n`).Replace(m.help))
+		fmt.Fprintf(w, "# TYPE %s %s\n", m.name, m.kind)
+
+		if m.fn != nil {
+			fmt.Fprintf(w, "%s %s\n", m.name, formatFloat(m.fn()))
+			m.mu.Unlock()
+			continue
+		}
+
+		keys := make([]string, 0, len(m.series))
+		for k := range m.series {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			s := m.series[k]
+			if m.kind != kindHistogram {
+				fmt.Fprintf(w, "%s%s %s\n", m.name, labels(m.labels, s.values, ""), formatFloat(s.value))
+				continue
+			}
+			for i, b := range m.buckets {
+				fmt.Fprintf(w, "%s_bucket%s %d\n", m.name, labels(m.labels, s.values, formatFloat(b)), s.counts[i])
+			}
+			fmt.Fprintf(w, "%s_bucket%s %d\n", m.name, labels(m.labels, s.values, "+Inf"), s.count)
+			fmt.Fprintf(w, "%s_sum%s %s\n", m.name, labels(m.labels, s.values, ""), formatFloat(s.value))
+			fmt.Fprintf(w, "%s_count%s %d\n", m.name, labels(m.labels, s.values, ""), s.count)
+		}
+		m.mu.Unlock()
+	}
+}
+
+// labels renders {name="value",...} with an optional le label for buckets.
+func labels(names, values []string, le string) string {
+	if len(names) == 0 && le == "" {
+		return ""
+	}
+	escape := strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+	var sb strings.Builder
+	sb.WriteByte('{')
+	for i, n := range names {
+		if i > 0 {
+			sb.WriteByte(',')
+		}
+		sb.WriteString(n + `="` + escape.Replace(values[i]) + `"`)
+	}
+	if le != "" {
+		if len(names) > 0 {
+			sb.WriteByte(',')
+		}
+		sb.WriteString(`le="` + le + `"`)
+	}
+	sb.WriteByte('}')
+	return sb.String()
+}
+
+func formatFloat(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	case math.IsNaN(v):
+		return "NaN"
+	default:
+		return strconv.FormatFloat(v, 'g', -1, 64)
+	}
+}
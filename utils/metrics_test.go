@@ -0,0 +1,113 @@
+package utils
+
+import (
+	"github.com/google/uuid"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestMetrics(t *testing.T) {
+	t.Parallel()
+
+	t.Run("should render text exposition format", func(t *testing.T) {
+		t.Parallel()
+
+		// given
+		reg := MetricsRegistry()
+		reg.Counter("jobs_total", "Jobs run.", "queue").Add(2, `mail "eu"`)
+		reg.Gauge("workers", "Busy workers.").Set(3)
+		h := reg.Histogram("job_seconds", "Job latency.", []float64{1, 0.5}, "queue")
+		h.Observe(0.25, "mail")
+		h.Observe(0.75, "mail")
+		reg.GaugeFunc("uptime_seconds", "Uptime.", func() float64 { return 1.5 })
+
+		// method to test
+		w := httptest.NewRecorder()
+		MetricsHandler(reg).ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+
+		// assert
+		if ct := w.Header().Get("Content-Type"); ct != "text/plain; version=0.0.4; charset=utf-8" {
+			t.Errorf("expect prometheus content type, given %s", ct)
+		}
+		expect := `# HELP jobs_total Jobs run.
+# TYPE jobs_total counter
+jobs_total{queue="mail \"eu\""} 2
+# HELP workers Busy workers.
+# TYPE workers gauge
+workers 3
+# HELP job_seconds Job latency.
+# TYPE job_seconds histogram
+job_seconds_bucket{queue="mail",le="0.5"} 1
+job_seconds_bucket{queue="mail",le="1"} 2
+job_seconds_bucket{queue="mail",le="+Inf"} 2
+job_seconds_sum{queue="mail"} 1
+job_seconds_count{queue="mail"} 2
+# HELP uptime_seconds Uptime.
+# TYPE uptime_seconds gauge
+uptime_seconds 1.5
+`
+		if w.Body.String() != expect {
+			t.Errorf("expect\n%s\ngiven\n%s", expect, w.Body.String())
+		}
+	})
+
+	t.Run("should share metrics registered twice and reject conflicts", func(t *testing.T) {
+		t.Parallel()
+
+		// given
+		reg := MetricsRegistry()
+		reg.Counter("hits_total", "Hits.", "path").Inc("/")
+		reg.Counter("hits_total", "Hits.", "path").Inc("/")
+
+		// method to test
+		defer func() {
+			if recover() == nil {
+				t.Error("expect panic on conflicting registration")
+			}
+		}()
+		w := httptest.NewRecorder()
+		MetricsHandler(reg).ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+
+		// assert
+		if !strings.Contains(w.Body.String(), `hits_total{path="/"} 2`) {
+			t.Errorf("expect shared counter, given %s", w.Body.String())
+		}
+		reg.Gauge("hits_total", "Hits.")
+	})
+
+	t.Run("should count shipped and dropped sink entries", func(t *testing.T) {
+		t.Parallel()
+
+		// given
+		// unique per run as DefaultRegistry outlives -count
+		sink := "metrics-test-" + uuid.NewString()
+		b := newBatcher(sink, BatchConfig{Size: 1, QueueSize: 1, MaxRetries: 0}, func(batch []queued) ([]queued, int, error) {
+			return nil, 0, nil
+		})
+
+		// method to test
+		_ = b.add(&Entry{Info: "ok"})
+		b.close()
+		b = newBatcher(sink, BatchConfig{Size: 1, QueueSize: 1}, func(batch []queued) ([]queued, int, error) {
+			return nil, len(batch), io.ErrUnexpectedEOF
+		})
+		_ = b.add(&Entry{Info: "fail"})
+		b.close()
+
+		// assert
+		w := httptest.NewRecorder()
+		MetricsHandler(DefaultRegistry).ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+		for _, line := range []string{
+			`log_sink_queue_length{sink="` + sink + `"} 0`,
+			`log_sink_shipped_total{sink="` + sink + `"} 1`,
+			`log_sink_dropped_total{sink="` + sink + `"} 1`,
+		} {
+			if !strings.Contains(w.Body.String(), line) {
+				t.Errorf("expect %s, given %s", line, w.Body.String())
+			}
+		}
+	})
+}
@@ -2,68 +2,85 @@ package utils
 
 import (
 	"context"
-	"fmt"
 	"log"
 	"time"
 )
 
 type mockLogger struct {
-	location   *time.Location
-	timeformat string
+	location *time.Location
+	fields   []Field
+	options
 }
 
-func DevLogger(timezone string) ILogger {
+func DevLogger(timezone string, opts ...Option) ILogger {
 	loc, err := Timezone(timezone)
 	if err != nil {
 		log.Fatal(err.Error())
 		return nil
 	}
-	return &mockLogger{location: loc, timeformat: time.RFC3339}
+	m := &mockLogger{location: loc, options: newOptions(opts)}
+	if err = validTimeFormat(m.timeFormat); err != nil {
+		log.Fatal(err.Error())
+		return nil
+	}
+	return m
 }
 
 func (m *mockLogger) Timezone() *time.Location {
 	return m.location
 }
 
+func (m *mockLogger) With(fields ...Field) ILogger {
+	c := *m
+	c.fields = append(append([]Field{}, m.fields...), fields...)
+	return &c
+}
+
+// Date returns the current time in the logger timezone at full precision.
 func (m *mockLogger) Date() time.Time {
-	dt, err := time.Parse(m.timeformat, time.Now().In(m.location).Format(m.timeformat))
-	if err != nil {
-		fmt.Print(err.Error())
+	return time.Now().In(m.location)
+}
+
+func (m *mockLogger) write(e *Entry) {
+	if !m.sample(e) {
+		return
+	}
+	forward := m.allow(e, m.Date, m.dispatch)
+	m.print(e)
+	if forward {
+		m.dispatch(e)
 	}
-	return dt
+}
+
+func (m *mockLogger) Debug(ctx context.Context, variables ...interface{}) {
+	if !m.levels.any(iDebug) {
+		return
+	}
+	m.write(m.logformat(ctx, iDebug, m.Date(), m.fields, variables...))
 }
 
 func (m *mockLogger) Error(ctx context.Context, variables ...interface{}) {
-	_, str, err := logformat(ctx, iError, m.Date(), variables)
-	if err != nil {
-		fmt.Print(err.Error())
+	if !m.levels.any(iError) {
 		return
 	}
-	fmt.Print(str)
+	m.write(m.logformat(ctx, iError, m.Date(), m.fields, variables...))
 }
 
 func (m *mockLogger) Log(ctx context.Context, variables ...interface{}) {
-	_, str, err := logformat(ctx, iLog, m.Date(), variables)
-	if err != nil {
-		fmt.Print(err.Error())
+	if !m.levels.any(iLog) {
 		return
 	}
-	fmt.Print(str)
+	m.write(m.logformat(ctx, iLog, m.Date(), m.fields, variables...))
 }
 
 func (m *mockLogger) Fatal(variables ...interface{}) {
-	_, str, err := logformat(context.Background(), iFatal, m.Date(), variables)
-	if err != nil {
-		log.Fatal(err.Error())
-	}
-	log.Fatal(str)
+	e := m.logformat(context.Background(), iFatal, m.Date(), m.fields, variables...)
+	m.fatal(func() { m.write(e) })
 }
 
 func (m *mockLogger) Critical(ctx context.Context, variables ...interface{}) {
-	_, str, err := logformat(ctx, iCritical, m.Date(), variables)
-	if err != nil {
-		fmt.Print(err.Error())
+	if !m.levels.any(iCritical) {
 		return
 	}
-	fmt.Print(str)
+	m.write(m.logformat(ctx, iCritical, m.Date(), m.fields, variables...))
 }
@@ -0,0 +1,95 @@
+package utils
+
+import (
+	"io"
+	"os"
+	"sync"
+	"time"
+)
+
+// Option configures behaviour shared by ProdLogger and DevLogger.
+type Option func(*options)
+
+type options struct {
+	encoder  Encoder
+	out      io.Writer
+	sinks    []namedSink
+	redactor *redactor
+	deduper  *deduper
+	// callerSkip and stacktrace are set by WithCallerSkip and WithStacktrace
+	callerSkip int
+	stacktrace bool
+	levels     *levels
+	sampler    *sampler
+	routes     []Route
+	shutdown   *shutdown
+	timeFormat string
+}
+
+// WithEncoder sets how entries are rendered. Defaults to JSONEncoder.
+func WithEncoder(e Encoder) Option {
+	return func(o *options) {
+		o.encoder = e
+	}
+}
+
+// WithOutput sets where rendered entries are written. Defaults to os.Stdout.
+func WithOutput(w io.Writer) Option {
+	return func(o *options) {
+		o.out = &syncWriter{w: w}
+	}
+}
+
+func newOptions(opts []Option) options {
+	o := options{levels: newLevels(), shutdown: newShutdown()}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	o.levels.register(stdoutOutput)
+	for _, s := range o.sinks {
+		o.levels.register(s.name)
+	}
+	return o
+}
+
+// allow reports whether e is forwarded past the logger output.
+func (o *options) allow(e *Entry, date func() time.Time, emit func(*Entry)) bool {
+	if o.deduper == nil {
+		return true
+	}
+	return o.deduper.allow(e, date, emit)
+}
+
+// print renders e with the configured encoder and writes it in a single call.
+func (o *options) print(e *Entry) {
+	if !o.levels.enabled(stdoutOutput, e.Status) {
+		return
+	}
+	enc := o.encoder
+	if enc == nil {
+		enc = JSONEncoder()
+	}
+	out := o.out
+	if out == nil {
+		out = os.Stdout
+	}
+
+	by, err := enc.Encode(e)
+	if err != nil {
+		o.fail(err.Error())
+		return
+	}
+	_, _ = out.Write(by)
+}
+
+// syncWriter serialises writes so concurrent entries are never interleaved.
+type syncWriter struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+func (s *syncWriter) Write(p []byte) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.w.Write(p)
+}
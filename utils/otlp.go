@@ -0,0 +1,164 @@
+package utils
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type OTLPConfig struct {
+	// Endpoint of the collector e.g. http://localhost:4318. Spans are posted
+	// to Endpoint/v1/traces.
+	Endpoint string
+	// Headers are added to every request e.g. an API key.
+	Headers map[string]string
+	// Client defaults to an http.Client with a 10 second timeout.
+	Client *http.Client
+}
+
+type otlpExporter struct {
+	cfg OTLPConfig
+	url string
+}
+
+// OTLPExporter sends spans to an OpenTelemetry collector using OTLP/HTTP with
+// the JSON encoding.
+func OTLPExporter(cfg OTLPConfig) (SpanExporter, error) {
+	if cfg.Endpoint == "" {
+		return nil, errors.New("otlp exporter: endpoint is required")
+	}
+	if cfg.Client == nil {
+		cfg.Client = &http.Client{Timeout: 10 * time.Second}
+	}
+	return &otlpExporter{cfg: cfg, url: strings.TrimSuffix(cfg.Endpoint, "/") + "/v1/traces"}, nil
+}
+
+type otlpValue struct {
+	StringValue *string  `json:"stringValue,omitempty"`
+	BoolValue   *bool    `json:"boolValue,omitempty"`
+	IntValue    *string  `json:"intValue,omitempty"`
+	DoubleValue *float64 `json:"doubleValue,omitempty"`
+}
+
+type otlpAttribute struct {
+	Key   string    `json:"key"`
+	Value otlpValue `json:"value"`
+}
+
+type otlpSpan struct {
+	TraceID           string          `json:"traceId"`
+	SpanID            string          `json:"spanId"`
+	ParentSpanID      string          `json:"parentSpanId,omitempty"`
+	TraceState        string          `json:"traceState,omitempty"`
+	Name              string          `json:"name"`
+	Kind              SpanKind        `json:"kind"`
+	StartTimeUnixNano string          `json:"startTimeUnixNano"`
+	EndTimeUnixNano   string          `json:"endTimeUnixNano"`
+	Attributes        []otlpAttribute `json:"attributes,omitempty"`
+	Status            struct {
+		Code    SpanStatus `json:"code,omitempty"`
+		Message string     `json:"message,omitempty"`
+	} `json:"status"`
+}
+
+// attribute maps a Field to an OTLP value. 64 bit integers are strings in
+// the JSON encoding and anything without an OTLP type is rendered as text.
+func attribute(f Field) otlpAttribute {
+	var v otlpValue
+	switch x := f.Value.(type) {
+	case bool:
+		v.BoolValue = &x
+	case int:
+		s := strconv.Itoa(x)
+		v.IntValue = &s
+	case int64:
+		s := strconv.FormatInt(x, 10)
+		v.IntValue = &s
+	case float64:
+		v.DoubleValue = &x
+	default:
+		s := f.String()
+		v.StringValue = &s
+	}
+	return otlpAttribute{Key: f.Key, Value: v}
+}
+
+func (o *otlpExporter) Export(ctx context.Context, spans []SpanData) error {
+	type scopeSpans struct {
+		Scope struct {
+			Name string `json:"name"`
+		} `json:"scope"`
+		Spans []otlpSpan `json:"spans"`
+	}
+	type resourceSpans struct {
+		Resource struct {
+			Attributes []otlpAttribute `json:"attributes"`
+		} `json:"resource"`
+		ScopeSpans []scopeSpans `json:"scopeSpans"`
+	}
+
+	var resources []*resourceSpans
+	index := map[string]*resourceSpans{}
+	for _, s := range spans {
+		rs, ok := index[s.Service]
+		if !ok {
+			rs = &resourceSpans{ScopeSpans: make([]scopeSpans, 1)}
+			rs.Resource.Attributes = []otlpAttribute{attribute(String("service.name", s.Service))}
+			rs.ScopeSpans[0].Scope.Name = "github.com/iTchTheRightSpot/utility"
+			index[s.Service] = rs
+			resources = append(resources, rs)
+		}
+
+		sp := otlpSpan{
+			TraceID:           s.SpanContext.TraceID.String(),
+			SpanID:            s.SpanContext.SpanID.String(),
+			TraceState:        s.SpanContext.TraceState,
+			Name:              s.Name,
+			Kind:              s.Kind,
+			StartTimeUnixNano: strconv.FormatInt(s.Start.UnixNano(), 10),
+			EndTimeUnixNano:   strconv.FormatInt(s.End.UnixNano(), 10),
+		}
+		if s.Parent.IsValid() {
+			sp.ParentSpanID = s.Parent.String()
+		}
+		for _, f := range s.Attributes {
+			sp.Attributes = append(sp.Attributes, attribute(f))
+		}
+		sp.Status.Code = s.Status
+		sp.Status.Message = s.StatusMessage
+		rs.ScopeSpans[0].Spans = append(rs.ScopeSpans[0].Spans, sp)
+	}
+
+	body, err := json.Marshal(map[string]interface{}{"resourceSpans": resources})
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, o.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range o.cfg.Headers {
+		req.Header.Set(k, v)
+	}
+	res, err := o.cfg.Client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = res.Body.Close() }()
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		return fmt.Errorf("otlp exporter: %w", &statusError{code: res.StatusCode})
+	}
+	return nil
+}
+
+func (o *otlpExporter) Shutdown(context.Context) error {
+	return nil
+}
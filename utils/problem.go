@@ -0,0 +1,210 @@
+package utils
+
+import (
+	"context"
+	"encoding/json"
+	htmltemplate "html/template"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// Problem is an RFC 9457 problem details object. Returned as an error it is
+// written with its own status and members.
+type Problem struct {
+	Type     string
+	Title    string
+	Status   int
+	Detail   string
+	Instance string
+	// RequestId defaults to the id of the request being answered.
+	RequestId string
+	// Extensions are extra members e.g. a balance or invalid params. They
+	// never replace the members above.
+	Extensions map[string]interface{}
+}
+
+func (p *Problem) Error() string {
+	if p.Detail != "" {
+		return p.Detail
+	}
+	if p.Title != "" {
+		return p.Title
+	}
+	return strings.ToLower(http.StatusText(p.Status))
+}
+
+func (p *Problem) MarshalJSON() ([]byte, error) {
+	m := make(map[string]interface{}, len(p.Extensions)+6)
+	for k, v := range p.Extensions {
+		m[k] = v
+	}
+	for k, v := range map[string]string{"type": p.Type, "title": p.Title, "detail": p.Detail, "instance": p.Instance, "request_id": p.RequestId} {
+		if v != "" {
+			m[k] = v
+		} else {
+			delete(m, k)
+		}
+	}
+	m["status"] = p.Status
+	return json.Marshal(m)
+}
+
+type ErrorFormat int
+
+const (
+	// MessageFormat writes {"message": ...} as application/json.
+	MessageFormat ErrorFormat = iota
+	// ProblemFormat writes RFC 9457 application/problem+json.
+	ProblemFormat
+)
+
+// ErrorConfig opts WriteError into problem details and content negotiation.
+type ErrorConfig struct {
+	Format ErrorFormat
+	// TypeBase is joined with the status e.g. https://example.com/problems/
+	// gives https://example.com/problems/404. Empty uses about:blank.
+	TypeBase string
+	// HTML is rendered with the Problem for clients preferring text/html
+	// e.g. browsers hitting a single page application. nil always writes JSON.
+	HTML *htmltemplate.Template
+}
+
+// DefaultErrorPage is a minimal HTML page for ErrorConfig.HTML.
+var DefaultErrorPage = htmltemplate.Must(htmltemplate.New("error").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head><meta charset="utf-8"><title>{{.Status}} {{.Title}}</title></head>
+<body>
+<h1>{{.Status}} {{.Title}}</h1>
+<p>{{.Detail}}</p>
+{{if .RequestId}}<p><small>request id {{.RequestId}}</small></p>{{end}}
+</body>
+</html>
+`))
+
+type errorConfigKey struct{}
+
+// ContextWithErrorConfig makes WriteError answer requests carrying ctx with cfg.
+func ContextWithErrorConfig(ctx context.Context, cfg *ErrorConfig) context.Context {
+	return context.WithValue(ctx, errorConfigKey{}, cfg)
+}
+
+// WriteError is ErrorResponse for handlers holding the request. With an
+// ErrorConfig in the request context it writes problem details and HTML to
+// clients asking for it, otherwise it is ErrorResponse.
+func WriteError(w http.ResponseWriter, r *http.Request, err error) {
+	cfg, _ := r.Context().Value(errorConfigKey{}).(*ErrorConfig)
+	if cfg == nil {
+		ErrorResponse(w, err)
+		return
+	}
+
+	html := cfg.HTML != nil && prefersHTML(r.Header.Get("Accept"))
+	if !html && cfg.Format != ProblemFormat {
+		ErrorResponse(w, err)
+		return
+	}
+
+	m := describe(err)
+	p := cfg.problem(r, err, m)
+	errorHeaders(w, m)
+	if html {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.WriteHeader(p.Status)
+		_ = cfg.HTML.Execute(w, p)
+		return
+	}
+	w.Header().Set("Content-Type", "application/problem+json")
+	w.WriteHeader(p.Status)
+	if er := json.NewEncoder(w).Encode(p); er != nil {
+		http.Error(w, err.Error(), p.Status)
+	}
+}
+
+// problem fills the members err leaves blank from the request.
+func (c *ErrorConfig) problem(r *http.Request, err error, m ErrorMapping) *Problem {
+	p := &Problem{Status: m.Status, Detail: m.Message}
+	if m.Code != "" || len(m.Details) > 0 {
+		p.Extensions = map[string]interface{}{}
+		if m.Code != "" {
+			p.Extensions["code"] = m.Code
+		}
+		if len(m.Details) > 0 {
+			p.Extensions["details"] = m.Details
+		}
+	}
+	if given, ok := asProblem(err); ok {
+		cp := *given
+		p = &cp
+		if p.Status == 0 {
+			p.Status = http.StatusInternalServerError
+		}
+		if p.Detail == "" && p.Title == "" {
+			p.Detail = p.Error()
+		}
+	}
+	if p.Type == "" {
+		p.Type = "about:blank"
+		if c.TypeBase != "" {
+			p.Type = c.TypeBase + strconv.Itoa(p.Status)
+		}
+	}
+	if p.Title == "" {
+		p.Title = http.StatusText(p.Status)
+	}
+	if p.Instance == "" {
+		p.Instance = r.URL.Path
+	}
+	if b, ok := r.Context().Value(RequestKey).(*RequestBody); ok && p.RequestId == "" {
+		p.RequestId = b.Id
+	}
+	return p
+}
+
+// prefersHTML reports whether accept ranks text/html above JSON, so clients
+// sending */* alone still get JSON.
+func prefersHTML(accept string) bool {
+	if accept == "" {
+		return false
+	}
+	html := acceptQuality(accept, "text/html")
+	data := max(acceptQuality(accept, "application/json"), acceptQuality(accept, "application/problem+json"))
+	return html > data
+}
+
+// acceptQuality is the q value of the most specific range of accept matching
+// mediaType, 0 when none does.
+func acceptQuality(accept, mediaType string) float64 {
+	typ, sub, _ := strings.Cut(mediaType, "/")
+	q, specificity := 0.0, -1
+	for _, part := range strings.Split(accept, ",") {
+		mt, params, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err != nil {
+			continue
+		}
+		t, s, _ := strings.Cut(mt, "/")
+		var rank int
+		switch {
+		case t == typ && s == sub:
+			rank = 2
+		case t == typ && s == "*":
+			rank = 1
+		case t == "*" && s == "*":
+			rank = 0
+		default:
+			continue
+		}
+		if rank <= specificity {
+			continue
+		}
+		specificity = rank
+		q = 1
+		if v, ok := params["q"]; ok {
+			if f, err := strconv.ParseFloat(v, 64); err == nil {
+				q = f
+			}
+		}
+	}
+	return q
+}
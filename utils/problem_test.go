@@ -0,0 +1,126 @@
+package utils
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestWriteError(t *testing.T) {
+	t.Parallel()
+
+	request := func(accept string, cfg *ErrorConfig) *http.Request {
+		r := httptest.NewRequest(http.MethodGet, "/api/orders/42", nil)
+		if accept != "" {
+			r.Header.Set("Accept", accept)
+		}
+		ctx := context.WithValue(r.Context(), RequestKey, &RequestBody{Id: "req-1"})
+		if cfg != nil {
+			ctx = ContextWithErrorConfig(ctx, cfg)
+		}
+		return r.WithContext(ctx)
+	}
+
+	t.Run("should write problem details", func(t *testing.T) {
+		t.Parallel()
+
+		// given
+		cfg := &ErrorConfig{Format: ProblemFormat, TypeBase: "https://example.com/problems/"}
+		err := &Problem{Status: http.StatusPaymentRequired, Detail: "balance too low", Extensions: map[string]interface{}{"balance": 30, "status": "ignored"}}
+
+		// method to test
+		w := httptest.NewRecorder()
+		WriteError(w, request("application/json", cfg), err)
+
+		// assert
+		if ct := w.Header().Get("Content-Type"); ct != "application/problem+json" || w.Code != http.StatusPaymentRequired {
+			t.Errorf("expect 402 problem+json, given %d %s", w.Code, ct)
+		}
+		var body map[string]interface{}
+		if er := json.Unmarshal(w.Body.Bytes(), &body); er != nil {
+			t.Error(er.Error())
+			t.FailNow()
+		}
+		expect := map[string]interface{}{
+			"type":       "https://example.com/problems/402",
+			"title":      "Payment Required",
+			"status":     float64(402),
+			"detail":     "balance too low",
+			"instance":   "/api/orders/42",
+			"request_id": "req-1",
+			"balance":    float64(30),
+		}
+		for k, v := range expect {
+			if body[k] != v {
+				t.Errorf("expect %s %v, given %v", k, v, body[k])
+			}
+		}
+	})
+
+	t.Run("should map built in errors to problems", func(t *testing.T) {
+		t.Parallel()
+
+		// method to test
+		w := httptest.NewRecorder()
+		WriteError(w, request("", &ErrorConfig{Format: ProblemFormat}), &TooManyRequestsError{RetryAfter: 1500 * time.Millisecond})
+
+		// assert
+		if w.Code != http.StatusTooManyRequests || w.Header().Get("Retry-After") != "2" {
+			t.Errorf("expect 429 with Retry-After, given %d %s", w.Code, w.Header().Get("Retry-After"))
+		}
+		if !strings.Contains(w.Body.String(), `"type":"about:blank"`) || !strings.Contains(w.Body.String(), `"detail":"too many requests"`) {
+			t.Errorf("expect about:blank problem, given %s", w.Body.String())
+		}
+	})
+
+	t.Run("should negotiate html for browsers only", func(t *testing.T) {
+		t.Parallel()
+
+		cfg := &ErrorConfig{HTML: DefaultErrorPage}
+		cases := []struct {
+			accept string
+			html   bool
+		}{
+			{"text/html,application/xhtml+xml,application/xml;q=0.9,*/*;q=0.8", true},
+			{"*/*", false},
+			{"", false},
+			{"application/json, text/html;q=0.5", false},
+			{"text/*, application/json;q=0.1", true},
+		}
+
+		for _, c := range cases {
+			// method to test
+			w := httptest.NewRecorder()
+			WriteError(w, request(c.accept, cfg), &NotFoundError{})
+
+			// assert
+			ct := w.Header().Get("Content-Type")
+			if strings.HasPrefix(ct, "text/html") != c.html || w.Code != http.StatusNotFound {
+				t.Errorf("%q: expect html %v, given %d %s", c.accept, c.html, w.Code, ct)
+			}
+			if c.html && !strings.Contains(w.Body.String(), "<h1>404 Not Found</h1>") {
+				t.Errorf("expect error page, given %s", w.Body.String())
+			}
+			if !c.html && w.Body.String() != "{\"message\":\"not found\"}\n" {
+				t.Errorf("expect message body, given %s", w.Body.String())
+			}
+		}
+	})
+
+	t.Run("should fall back to ErrorResponse without config", func(t *testing.T) {
+		t.Parallel()
+
+		// method to test
+		w := httptest.NewRecorder()
+		WriteError(w, request("text/html", nil), &BadRequestError{})
+
+		// assert
+		if w.Header().Get("Content-Type") != "application/json" || w.Body.String() != "{\"message\":\"bad request\"}\n" {
+			t.Errorf("expect message body, given %s", w.Body.String())
+		}
+	})
+}
@@ -0,0 +1,196 @@
+package utils
+
+import (
+	"context"
+	"io"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Query selects recorded entries. Empty members match everything; Message
+// matches as a substring.
+type Query struct {
+	Level     string
+	Message   string
+	RequestId string
+	Path      string
+	// Field matches entries having a field with this key
+	Field string
+}
+
+func (q Query) match(e *Entry) bool {
+	if q.Level != "" && !strings.EqualFold(q.Level, string(e.Status)) {
+		return false
+	}
+	if q.Message != "" && !strings.Contains(e.Info, q.Message) {
+		return false
+	}
+	if q.RequestId != "" && q.RequestId != e.Id {
+		return false
+	}
+	if q.Path != "" && q.Path != e.Path {
+		return false
+	}
+	if q.Field != "" {
+		for _, f := range e.Fields {
+			if f.Key == q.Field {
+				return true
+			}
+		}
+		return false
+	}
+	return true
+}
+
+type recording struct {
+	mu      sync.Mutex
+	entries []Entry
+}
+
+func (r *recording) Write(e *Entry) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.entries = append(r.entries, *e)
+	return nil
+}
+
+func (r *recording) Close() error {
+	return nil
+}
+
+// Recorder is an ILogger for tests. It keeps every entry in memory instead of
+// printing it and Fatal records the entry without exiting the process.
+type Recorder struct {
+	logger *mockLogger
+	store  *recording
+}
+
+// RecordingLogger returns a Recorder. opts are applied like DevLogger, so
+// redaction or caller options can be asserted on as well.
+func RecordingLogger(opts ...Option) *Recorder {
+	store := &recording{}
+	opts = append([]Option{WithOutput(io.Discard)}, opts...)
+	opts = append(opts, WithSink("recorder", store), func(o *options) {
+		// one extra frame for the Recorder method wrapping mockLogger, added
+		// to any WithCallerSkip in opts
+		o.callerSkip++
+	})
+	return &Recorder{
+		logger: DevLogger("UTC", opts...).(*mockLogger),
+		store:  store,
+	}
+}
+
+func (r *Recorder) Date() time.Time {
+	return r.logger.Date()
+}
+
+func (r *Recorder) Timezone() *time.Location {
+	return r.logger.Timezone()
+}
+
+func (r *Recorder) With(fields ...Field) ILogger {
+	return &Recorder{logger: r.logger.With(fields...).(*mockLogger), store: r.store}
+}
+
+func (r *Recorder) Debug(ctx context.Context, variables ...interface{}) {
+	r.logger.Debug(ctx, variables...)
+}
+
+func (r *Recorder) Error(ctx context.Context, variables ...interface{}) {
+	r.logger.Error(ctx, variables...)
+}
+
+func (r *Recorder) Log(ctx context.Context, variables ...interface{}) {
+	r.logger.Log(ctx, variables...)
+}
+
+func (r *Recorder) Critical(ctx context.Context, variables ...interface{}) {
+	r.logger.Critical(ctx, variables...)
+}
+
+func (r *Recorder) Fatal(variables ...interface{}) {
+	r.fatal(variables...)
+}
+
+// fatal keeps the same frame depth as the other methods for caller resolution.
+func (r *Recorder) fatal(variables ...interface{}) {
+	m := r.logger
+	m.write(m.logformat(context.Background(), iFatal, m.Date(), m.fields, variables...))
+}
+
+// Entries returns a copy of every recorded entry in logging order.
+func (r *Recorder) Entries() []Entry {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+	return append([]Entry{}, r.store.entries...)
+}
+
+// Find returns recorded entries matching q.
+func (r *Recorder) Find(q Query) []Entry {
+	var res []Entry
+	for _, e := range r.Entries() {
+		if q.match(&e) {
+			res = append(res, e)
+		}
+	}
+	return res
+}
+
+func (r *Recorder) Count(q Query) int {
+	return len(r.Find(q))
+}
+
+// Logged reports whether any entry contains message.
+func (r *Recorder) Logged(message string) bool {
+	return r.Count(Query{Message: message}) > 0
+}
+
+// Reset drops every recorded entry e.g. between subtests.
+func (r *Recorder) Reset() {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+	r.store.entries = nil
+}
+
+// ITestReporter is the part of testing.TB the assertions use, so utils does
+// not import testing.
+type ITestReporter interface {
+	Helper()
+	Errorf(format string, args ...interface{})
+}
+
+func (r *Recorder) AssertLogged(t ITestReporter, q Query) {
+	t.Helper()
+	if r.Count(q) == 0 {
+		t.Errorf("expect entry matching %+v, given %s", q, r.dump())
+	}
+}
+
+func (r *Recorder) AssertNotLogged(t ITestReporter, q Query) {
+	t.Helper()
+	if n := r.Count(q); n > 0 {
+		t.Errorf("expect no entry matching %+v, given %d", q, n)
+	}
+}
+
+func (r *Recorder) AssertCount(t ITestReporter, q Query, n int) {
+	t.Helper()
+	if c := r.Count(q); c != n {
+		t.Errorf("expect %d entries matching %+v, given %d", n, q, c)
+	}
+}
+
+func (r *Recorder) dump() string {
+	var sb strings.Builder
+	sb.WriteString("[")
+	for i, e := range r.Entries() {
+		if i > 0 {
+			sb.WriteString(", ")
+		}
+		sb.WriteString(string(e.Status) + " " + e.Info)
+	}
+	sb.WriteString("]")
+	return sb.String()
+}
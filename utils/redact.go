@@ -0,0 +1,234 @@
+package utils
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"regexp"
+	"strings"
+	"time"
+)
+
+const defaultMask = "[REDACTED]"
+
+// maxRedactDepth bounds how deep nested values are walked.
+const maxRedactDepth = 10
+
+var (
+	jwtPattern    = regexp.MustCompile(`eyJ[A-Za-z0-9_-]+\.[A-Za-z0-9_-]+\.[A-Za-z0-9_-]*`)
+	bearerPattern = regexp.MustCompile(`(?i)(bearer\s+)[A-Za-z0-9\-._~+/]+=*`)
+	emailPattern  = regexp.MustCompile(`[A-Za-z0-9._%+\-]+@[A-Za-z0-9.\-]+\.[A-Za-z]{2,}`)
+	panPattern    = regexp.MustCompile(`\b(?:\d[ -]?){12,18}\d\b`)
+)
+
+// defaultRedactKeys are masked regardless of their value when built-in rules are enabled.
+var defaultRedactKeys = []string{"password", "passwd", "secret", "token", "access_token", "refresh_token", "authorization", "cookie", "api_key", "apikey"}
+
+type RedactionConfig struct {
+	// Patterns are additional expressions whose matches are replaced by Mask.
+	Patterns []*regexp.Regexp
+	// Keys are field, map and struct keys whose whole value is replaced by Mask. Case-insensitive.
+	Keys []string
+	// Mask replaces redacted values. Defaults to [REDACTED].
+	Mask string
+	// DisableBuiltins turns off the JWT, bearer token, email and card number
+	// detectors and the default sensitive key names.
+	DisableBuiltins bool
+}
+
+type redactor struct {
+	patterns []*regexp.Regexp
+	keys     map[string]struct{}
+	mask     string
+	builtins bool
+}
+
+// WithRedaction masks sensitive data in the message, fields and path of every
+// entry before it reaches the output or any sink. Struct fields tagged
+// `log:"redact"` are always masked once redaction is enabled.
+func WithRedaction(cfg RedactionConfig) Option {
+	return func(o *options) {
+		r := &redactor{
+			patterns: cfg.Patterns,
+			keys:     map[string]struct{}{},
+			mask:     cfg.Mask,
+			builtins: !cfg.DisableBuiltins,
+		}
+		if r.mask == "" {
+			r.mask = defaultMask
+		}
+		keys := cfg.Keys
+		if r.builtins {
+			keys = append(append([]string{}, defaultRedactKeys...), keys...)
+		}
+		for _, k := range keys {
+			r.keys[strings.ToLower(k)] = struct{}{}
+		}
+		o.redactor = r
+	}
+}
+
+func (r *redactor) sensitive(key string) bool {
+	_, ok := r.keys[strings.ToLower(key)]
+	return ok
+}
+
+// text masks every detector and pattern match in s.
+func (r *redactor) text(s string) string {
+	if r.builtins {
+		s = jwtPattern.ReplaceAllString(s, r.mask)
+		s = bearerPattern.ReplaceAllString(s, "${1}"+r.mask)
+		s = emailPattern.ReplaceAllString(s, r.mask)
+		s = panPattern.ReplaceAllStringFunc(s, func(m string) string {
+			if luhn(m) {
+				return r.mask
+			}
+			return m
+		})
+	}
+	for _, p := range r.patterns {
+		s = p.ReplaceAllString(s, r.mask)
+	}
+	return s
+}
+
+// luhn reports whether the digits in s form a valid card number.
+func luhn(s string) bool {
+	sum, n := 0, 0
+	for i := len(s) - 1; i >= 0; i-- {
+		c := s[i]
+		if c < '0' || c > '9' {
+			continue
+		}
+		d := int(c - '0')
+		if n%2 == 1 {
+			d *= 2
+			if d > 9 {
+				d -= 9
+			}
+		}
+		sum += d
+		n++
+	}
+	return n >= 13 && sum%10 == 0
+}
+
+// field masks f entirely when its key is sensitive, otherwise its value.
+func (r *redactor) field(f Field) Field {
+	if r.sensitive(f.Key) {
+		return Field{Key: f.Key, Value: r.mask, err: f.err}
+	}
+	return Field{Key: f.Key, Value: r.value(f.Value, 0), err: f.err}
+}
+
+// part renders a message argument as redacted text. Composite values are
+// rendered as JSON so masked struct fields stay readable.
+func (r *redactor) part(v interface{}) string {
+	switch v.(type) {
+	case string, error, fmt.Stringer:
+		return r.text(fmt.Sprintf("%v", v))
+	}
+	switch reflect.Indirect(reflect.ValueOf(v)).Kind() {
+	case reflect.Struct, reflect.Map, reflect.Slice, reflect.Array:
+		if by, err := json.Marshal(r.value(v, 0)); err == nil {
+			return string(by)
+		}
+	}
+	return r.text(fmt.Sprintf("%v", v))
+}
+
+// value returns a copy of v with sensitive data masked. Structs and maps are
+// converted to map[string]interface{} keyed like encoding/json would.
+func (r *redactor) value(v interface{}, depth int) interface{} {
+	switch x := v.(type) {
+	case nil:
+		return nil
+	case string:
+		return r.text(x)
+	case time.Time, time.Duration:
+		return x
+	case error, fmt.Stringer:
+		return r.text(fmt.Sprintf("%v", x))
+	case []byte:
+		return r.text(string(x))
+	}
+
+	if depth >= maxRedactDepth {
+		return r.text(fmt.Sprintf("%v", v))
+	}
+
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Ptr, reflect.Interface:
+		if rv.IsNil() {
+			return nil
+		}
+		return r.value(rv.Elem().Interface(), depth+1)
+	case reflect.Struct:
+		m := map[string]interface{}{}
+		r.structFields(rv, m, depth)
+		return m
+	case reflect.Map:
+		if rv.Type().Key().Kind() != reflect.String {
+			return r.text(fmt.Sprintf("%v", v))
+		}
+		m := make(map[string]interface{}, rv.Len())
+		iter := rv.MapRange()
+		for iter.Next() {
+			k := iter.Key().String()
+			if r.sensitive(k) {
+				m[k] = r.mask
+				continue
+			}
+			m[k] = r.value(iter.Value().Interface(), depth+1)
+		}
+		return m
+	case reflect.Slice, reflect.Array:
+		if rv.Kind() == reflect.Slice && rv.IsNil() {
+			return nil
+		}
+		s := make([]interface{}, rv.Len())
+		for i := 0; i < rv.Len(); i++ {
+			s[i] = r.value(rv.Index(i).Interface(), depth+1)
+		}
+		return s
+	default:
+		return v
+	}
+}
+
+func (r *redactor) structFields(rv reflect.Value, m map[string]interface{}, depth int) {
+	rt := rv.Type()
+	for i := 0; i < rt.NumField(); i++ {
+		sf := rt.Field(i)
+		if !sf.IsExported() {
+			continue
+		}
+
+		name := sf.Name
+		if tag, ok := sf.Tag.Lookup("json"); ok {
+			tagName := strings.Split(tag, ",")[0]
+			if tagName == "-" {
+				continue
+			}
+			if tagName != "" {
+				name = tagName
+			}
+		}
+
+		fv := rv.Field(i)
+		if sf.Anonymous && name == sf.Name && reflect.Indirect(fv).Kind() == reflect.Struct {
+			if fv.Kind() == reflect.Ptr && fv.IsNil() {
+				continue
+			}
+			r.structFields(reflect.Indirect(fv), m, depth+1)
+			continue
+		}
+
+		if sf.Tag.Get("log") == "redact" || r.sensitive(name) {
+			m[name] = r.mask
+			continue
+		}
+		m[name] = r.value(fv.Interface(), depth+1)
+	}
+}
@@ -0,0 +1,218 @@
+package utils
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// Route decides which webhook receives a matching entry and who is mentioned.
+// Every matcher that is set must match; a Route without matchers matches all.
+type Route struct {
+	// Levels e.g. ERROR, CRITICAL. Case-insensitive.
+	Levels []string
+	// PathPrefix matches the request path.
+	PathPrefix string
+	// MatchError is called with every error passed to the log call e.g. with
+	// errors.As to match a type anywhere in its chain.
+	MatchError func(error) bool
+	// Message matches the entry message.
+	Message *regexp.Regexp
+
+	// Webhook receives the entry. Defaults to the logger webhook.
+	Webhook string
+	// Mentions e.g. @everyone, @here, <@&role-id> or <@user-id>
+	Mentions []string
+	// QuietHours suppresses Mentions while active. The entry is still delivered.
+	QuietHours *QuietHours
+	// Continue evaluates the following routes after this one matched.
+	Continue bool
+}
+
+// QuietHours is a daily window in the logger timezone, Start and End use the
+// 15:04 layout and the window may wrap past midnight e.g. 22:00 to 07:00.
+type QuietHours struct {
+	Start string
+	End   string
+	// Days the window applies to. Empty means every day.
+	Days []time.Weekday
+}
+
+// WithRoutes replaces the default alerting, which mentions @everyone on ERROR,
+// CRITICAL and FATAL, with routes evaluated in order. Entries matching no
+// route are delivered to the logger webhook without mentions.
+func WithRoutes(routes ...Route) Option {
+	return func(o *options) {
+		for _, r := range routes {
+			if r.QuietHours != nil {
+				if err := r.QuietHours.validate(); err != nil {
+					o.fail(err.Error())
+				}
+			}
+		}
+		o.routes = append(o.routes, routes...)
+	}
+}
+
+func (q *QuietHours) validate() error {
+	if _, err := time.Parse("15:04", q.Start); err != nil {
+		return fmt.Errorf("quiet hours start %q: %w", q.Start, err)
+	}
+	if _, err := time.Parse("15:04", q.End); err != nil {
+		return fmt.Errorf("quiet hours end %q: %w", q.End, err)
+	}
+	return nil
+}
+
+// active reports whether now, converted to tz, falls inside the window.
+func (q *QuietHours) active(now time.Time, tz *time.Location) bool {
+	if tz != nil {
+		now = now.In(tz)
+	}
+	start, err := time.Parse("15:04", q.Start)
+	if err != nil {
+		return false
+	}
+	end, err := time.Parse("15:04", q.End)
+	if err != nil {
+		return false
+	}
+
+	minute := now.Hour()*60 + now.Minute()
+	from := start.Hour()*60 + start.Minute()
+	to := end.Hour()*60 + end.Minute()
+
+	day := now.Weekday()
+	inside := false
+	switch {
+	case from == to:
+		inside = true
+	case from < to:
+		inside = minute >= from && minute < to
+	default:
+		inside = minute >= from || minute < to
+		// the early morning part of a wrapping window belongs to the previous day
+		if minute < to {
+			day = (day + 6) % 7
+		}
+	}
+	if !inside {
+		return false
+	}
+
+	if len(q.Days) == 0 {
+		return true
+	}
+	for _, d := range q.Days {
+		if d == day {
+			return true
+		}
+	}
+	return false
+}
+
+func (r *Route) match(e *Entry) bool {
+	if len(r.Levels) > 0 {
+		found := false
+		for _, l := range r.Levels {
+			if strings.EqualFold(l, string(e.Status)) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	if r.PathPrefix != "" && !strings.HasPrefix(e.Path, r.PathPrefix) {
+		return false
+	}
+	if r.Message != nil && !r.Message.MatchString(e.Info) {
+		return false
+	}
+	if r.MatchError != nil && !matchesError(e.errs, r.MatchError) {
+		return false
+	}
+	return true
+}
+
+func matchesError(errs []error, match func(error) bool) bool {
+	for _, err := range errs {
+		if match(err) {
+			return true
+		}
+	}
+	return false
+}
+
+type delivery struct {
+	webhook  string
+	mentions []string
+}
+
+// route returns the webhooks e is delivered to. fallback is the logger webhook
+// and tz the timezone quiet hours are evaluated in.
+func (o *options) route(e *Entry, fallback string, tz *time.Location, now time.Time) []delivery {
+	if len(o.routes) == 0 {
+		if fallback == "" {
+			return nil
+		}
+		d := delivery{webhook: fallback}
+		if e.Status.rank() >= iError.rank() {
+			d.mentions = []string{"@everyone"}
+		}
+		return []delivery{d}
+	}
+
+	var res []delivery
+	index := map[string]int{}
+	for i := range o.routes {
+		r := &o.routes[i]
+		if !r.match(e) {
+			continue
+		}
+
+		webhook := r.Webhook
+		if webhook == "" {
+			webhook = fallback
+		}
+		if webhook != "" {
+			var mentions []string
+			if r.QuietHours == nil || !r.QuietHours.active(now, tz) {
+				mentions = r.Mentions
+			}
+			if j, ok := index[webhook]; ok {
+				res[j].mentions = appendUnique(res[j].mentions, mentions...)
+			} else {
+				index[webhook] = len(res)
+				res = append(res, delivery{webhook: webhook, mentions: appendUnique(nil, mentions...)})
+			}
+		}
+
+		if !r.Continue {
+			return res
+		}
+	}
+
+	if len(res) == 0 && fallback != "" {
+		res = append(res, delivery{webhook: fallback})
+	}
+	return res
+}
+
+func appendUnique(s []string, values ...string) []string {
+	for _, v := range values {
+		found := false
+		for _, x := range s {
+			if x == v {
+				found = true
+				break
+			}
+		}
+		if !found {
+			s = append(s, v)
+		}
+	}
+	return s
+}
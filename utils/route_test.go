@@ -0,0 +1,150 @@
+package utils
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestRoutes(t *testing.T) {
+	t.Parallel()
+
+	lagos, err := time.LoadLocation("Africa/Lagos")
+	if err != nil {
+		t.Error(err.Error())
+		t.FailNow()
+	}
+
+	isServerError := func(err error) bool {
+		var e *ServerError
+		return errors.As(err, &e)
+	}
+	opts := newOptions([]Option{WithRoutes(
+		Route{Levels: []string{"critical"}, PathPrefix: "/api/payments", Webhook: "payments", Mentions: []string{"<@&payments>"}, Continue: true},
+		Route{MatchError: isServerError, Mentions: []string{"@here"}, QuietHours: &QuietHours{Start: "22:00", End: "07:00"}},
+		Route{Message: regexp.MustCompile(`^disk`), Webhook: "infra", Mentions: []string{"<@ops>"}},
+	)})
+
+	// 12:00 in Lagos (UTC+1)
+	noon := time.Date(2026, 10, 19, 11, 0, 0, 0, time.UTC)
+	// 23:30 in Lagos
+	night := time.Date(2026, 10, 19, 22, 30, 0, 0, time.UTC)
+
+	cases := []struct {
+		name   string
+		entry  *Entry
+		now    time.Time
+		expect []delivery
+	}{
+		{
+			name:   "path and level with continue",
+			entry:  &Entry{Status: iCritical, Path: "/api/payments/1", errs: []error{fmt.Errorf("charge: %w", &ServerError{})}},
+			now:    noon,
+			expect: []delivery{{webhook: "payments", mentions: []string{"<@&payments>"}}, {webhook: "default", mentions: []string{"@here"}}},
+		},
+		{
+			name:   "quiet hours drop mentions",
+			entry:  &Entry{Status: iError, errs: []error{&ServerError{}}},
+			now:    night,
+			expect: []delivery{{webhook: "default"}},
+		},
+		{
+			name:   "message match",
+			entry:  &Entry{Status: iError, Info: "disk full"},
+			now:    noon,
+			expect: []delivery{{webhook: "infra", mentions: []string{"<@ops>"}}},
+		},
+		{
+			name:   "no match falls back without mentions",
+			entry:  &Entry{Status: iError, Info: "other"},
+			now:    noon,
+			expect: []delivery{{webhook: "default"}},
+		},
+	}
+
+	for _, c := range cases {
+		// method to test
+		res := opts.route(c.entry, "default", lagos, c.now)
+
+		// assert
+		if fmt.Sprint(res) != fmt.Sprint(c.expect) {
+			t.Errorf("%s: expect %v, given %v", c.name, c.expect, res)
+		}
+	}
+
+	t.Run("default mentions everyone on error and above only", func(t *testing.T) {
+		t.Parallel()
+
+		// given
+		o := newOptions(nil)
+
+		// assert
+		for _, status := range []logType{iError, iCritical, iFatal} {
+			if res := o.route(&Entry{Status: status}, "default", nil, noon); len(res) != 1 || len(res[0].mentions) != 1 || res[0].mentions[0] != "@everyone" {
+				t.Errorf("expect @everyone on %s, given %v", status, res)
+			}
+		}
+		if res := o.route(&Entry{Status: iLog}, "default", nil, noon); len(res) != 1 || res[0].mentions != nil {
+			t.Errorf("expect no mentions, given %v", res)
+		}
+	})
+
+	t.Run("quiet hours on given days", func(t *testing.T) {
+		t.Parallel()
+
+		// given
+		q := &QuietHours{Start: "22:00", End: "07:00", Days: []time.Weekday{time.Friday}}
+
+		// assert
+		saturdayMorning := time.Date(2026, 10, 24, 3, 0, 0, 0, time.UTC)
+		if !q.active(saturdayMorning, time.UTC) {
+			t.Error("expect saturday 03:00 to belong to friday night")
+		}
+		if q.active(saturdayMorning.Add(24*time.Hour), time.UTC) {
+			t.Error("expect sunday 03:00 to be outside quiet hours")
+		}
+	})
+
+	t.Run("should post mentions to routed webhook", func(t *testing.T) {
+		t.Parallel()
+
+		// given
+		var mu sync.Mutex
+		received := map[string]map[string]interface{}{}
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			var body map[string]interface{}
+			_ = json.NewDecoder(r.Body).Decode(&body)
+			mu.Lock()
+			received[r.URL.Path] = body
+			mu.Unlock()
+			w.WriteHeader(http.StatusNoContent)
+		}))
+		defer srv.Close()
+
+		lg := ProdLogger(time.RFC3339, "UTC", srv.URL+"/default", WithOutput(io.Discard), WithRoutes(
+			Route{Levels: []string{"CRITICAL"}, Webhook: srv.URL + "/oncall", Mentions: []string{"<@&oncall>"}},
+		))
+
+		// method to test
+		lg.Critical(context.Background(), "database down")
+		lg.Error(context.Background(), "validation failed")
+
+		// assert
+		mu.Lock()
+		defer mu.Unlock()
+		if received["/oncall"]["content"] != "<@&oncall>" {
+			t.Errorf("expect on-call mention, given %v", received["/oncall"])
+		}
+		if _, ok := received["/default"]["content"]; ok {
+			t.Errorf("expect no mention on default webhook, given %v", received["/default"])
+		}
+	})
+}
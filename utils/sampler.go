@@ -0,0 +1,79 @@
+package utils
+
+import (
+	"net/http"
+	"sync"
+	"time"
+)
+
+type SamplingConfig struct {
+	// First entries with the same level and message are kept every Tick.
+	First int
+	// Thereafter keeps every Thereafter-th entry once First is exceeded. 0 drops the rest.
+	Thereafter int
+	// Tick is the sampling window. Defaults to 1 second.
+	Tick time.Duration
+}
+
+type sampler struct {
+	cfg    SamplingConfig
+	mu     sync.Mutex
+	start  time.Time
+	counts map[string]int
+}
+
+// WithSampling limits high-volume DEBUG and LOG entries. ERROR and above are
+// never sampled, and neither are entries carrying a 5xx "http_status" field
+// such as the access entry written by the logging middleware.
+func WithSampling(cfg SamplingConfig) Option {
+	return func(o *options) {
+		if cfg.Tick <= 0 {
+			cfg.Tick = time.Second
+		}
+		o.sampler = &sampler{cfg: cfg, counts: map[string]int{}}
+	}
+}
+
+// keep reports whether e survives sampling.
+func (s *sampler) keep(e *Entry, now time.Time) bool {
+	if e.Status.rank() >= iError.rank() || serverError(e) {
+		return true
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// counters are reset per window which also bounds memory to one window of messages
+	if now.Sub(s.start) >= s.cfg.Tick {
+		s.start = now
+		s.counts = map[string]int{}
+	}
+
+	key := string(e.Status) + "|" + e.Info
+	s.counts[key]++
+	n := s.counts[key]
+	if n <= s.cfg.First {
+		return true
+	}
+	return s.cfg.Thereafter > 0 && (n-s.cfg.First)%s.cfg.Thereafter == 0
+}
+
+// serverError reports whether e carries an http_status field of 500 or above.
+func serverError(e *Entry) bool {
+	for _, f := range e.Fields {
+		if f.Key != "http_status" {
+			continue
+		}
+		if code, ok := f.Value.(int); ok && code >= http.StatusInternalServerError {
+			return true
+		}
+	}
+	return false
+}
+
+func (o *options) sample(e *Entry) bool {
+	if o.sampler == nil {
+		return true
+	}
+	return o.sampler.keep(e, time.Now())
+}
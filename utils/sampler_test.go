@@ -0,0 +1,62 @@
+package utils
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestSampling(t *testing.T) {
+	t.Parallel()
+
+	t.Run("should keep first then every nth", func(t *testing.T) {
+		t.Parallel()
+
+		// given
+		s := &sampler{cfg: SamplingConfig{First: 2, Thereafter: 3, Tick: time.Second}, counts: map[string]int{}}
+		now := time.Now()
+
+		// method to test
+		var kept []int
+		for i := 1; i <= 10; i++ {
+			if s.keep(&Entry{Status: iLog, Info: "request completed"}, now) {
+				kept = append(kept, i)
+			}
+		}
+
+		// assert
+		expect := []int{1, 2, 5, 8}
+		if len(kept) != len(expect) {
+			t.Errorf("expect %v, given %v", expect, kept)
+			t.FailNow()
+		}
+		for i := range expect {
+			if kept[i] != expect[i] {
+				t.Errorf("expect %v, given %v", expect, kept)
+			}
+		}
+
+		// a new window resets the counters
+		if !s.keep(&Entry{Status: iLog, Info: "request completed"}, now.Add(time.Second)) {
+			t.Error("expect first entry of next window to be kept")
+		}
+	})
+
+	t.Run("should never sample errors or 5xx requests", func(t *testing.T) {
+		t.Parallel()
+
+		// given
+		lg := RecordingLogger(WithSampling(SamplingConfig{First: 1}))
+
+		// method to test
+		for i := 0; i < 5; i++ {
+			lg.Log(context.Background(), "request completed", Int("http_status", 200))
+			lg.Log(context.Background(), "request completed", Int("http_status", 503))
+			lg.Error(context.Background(), "failed")
+		}
+
+		// assert
+		lg.AssertCount(t, Query{Level: "LOG"}, 6)
+		lg.AssertCount(t, Query{Level: "ERROR"}, 5)
+	})
+}
@@ -0,0 +1,267 @@
+package utils
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestLokiSink(t *testing.T) {
+	t.Parallel()
+
+	t.Run("should push streams grouped by labels", func(t *testing.T) {
+		t.Parallel()
+
+		// given
+		var mu sync.Mutex
+		var bodies []map[string][]lokiStream
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Path != "/loki/api/v1/push" || r.Header.Get("X-Scope-OrgID") != "tenant" {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			var body map[string][]lokiStream
+			_ = json.NewDecoder(r.Body).Decode(&body)
+			mu.Lock()
+			bodies = append(bodies, body)
+			mu.Unlock()
+			w.WriteHeader(http.StatusNoContent)
+		}))
+		defer srv.Close()
+
+		s, err := LokiSink(LokiConfig{
+			URL:     srv.URL,
+			Service: "api",
+			Headers: map[string]string{"X-Scope-OrgID": "tenant"},
+			Batch:   BatchConfig{Size: 3, Interval: time.Hour},
+		})
+		if err != nil {
+			t.Error(err.Error())
+			t.FailNow()
+		}
+
+		// method to test
+		_ = s.Write(&Entry{Status: iLog, Path: "/a", Info: "one"})
+		_ = s.Write(&Entry{Status: iLog, Path: "/a", Info: "two"})
+		_ = s.Write(&Entry{Status: iError, Path: "/a", Info: "three"})
+		_ = s.Write(&Entry{Status: iLog, Info: "flushed on close"})
+		_ = s.Close()
+
+		// assert
+		mu.Lock()
+		defer mu.Unlock()
+		if len(bodies) != 2 {
+			t.Errorf("expect 2 pushes, given %d", len(bodies))
+			t.FailNow()
+		}
+
+		streams := bodies[0]["streams"]
+		if len(streams) != 2 {
+			t.Errorf("expect 2 streams, given %d", len(streams))
+			t.FailNow()
+		}
+		labels := streams[0].Stream
+		if labels["level"] != "log" || labels["service"] != "api" || labels["path"] != "/a" {
+			t.Errorf("expect level, service and path labels, given %v", labels)
+		}
+		if len(streams[0].Values) != 2 || !strings.Contains(streams[0].Values[1][1], `"info":"two"`) {
+			t.Errorf("expect 2 ordered values, given %v", streams[0].Values)
+		}
+	})
+
+	t.Run("should retry server errors", func(t *testing.T) {
+		t.Parallel()
+
+		// given
+		var calls atomic.Int32
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if calls.Add(1) < 3 {
+				w.WriteHeader(http.StatusServiceUnavailable)
+				return
+			}
+			w.WriteHeader(http.StatusNoContent)
+		}))
+		defer srv.Close()
+
+		s, _ := LokiSink(LokiConfig{URL: srv.URL, Batch: BatchConfig{Size: 1, Backoff: time.Millisecond}})
+
+		// method to test
+		_ = s.Write(&Entry{Status: iError, Info: "retry me"})
+		_ = s.Close()
+
+		// assert
+		if n := calls.Load(); n != 3 {
+			t.Errorf("expect 3 attempts, given %d", n)
+		}
+	})
+
+	t.Run("should not retry client errors", func(t *testing.T) {
+		t.Parallel()
+
+		// given
+		var calls atomic.Int32
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			calls.Add(1)
+			w.WriteHeader(http.StatusBadRequest)
+		}))
+		defer srv.Close()
+
+		s, _ := LokiSink(LokiConfig{URL: srv.URL, Batch: BatchConfig{Size: 1, Backoff: time.Millisecond}})
+
+		// method to test
+		_ = s.Write(&Entry{Status: iError, Info: "rejected"})
+		_ = s.Close()
+
+		// assert
+		if n := calls.Load(); n != 1 {
+			t.Errorf("expect 1 attempt, given %d", n)
+		}
+	})
+
+	t.Run("should drop entries when queue is full", func(t *testing.T) {
+		t.Parallel()
+
+		// given
+		release := make(chan struct{})
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			<-release
+			w.WriteHeader(http.StatusNoContent)
+		}))
+		defer srv.Close()
+
+		s, _ := LokiSink(LokiConfig{URL: srv.URL, Batch: BatchConfig{Size: 1, QueueSize: 2}})
+
+		// method to test
+		var err error
+		for i := 0; i < 10 && err == nil; i++ {
+			err = s.Write(&Entry{Status: iLog, Info: "flood"})
+			time.Sleep(5 * time.Millisecond)
+		}
+		close(release)
+		_ = s.Close()
+
+		// assert
+		if !errors.Is(err, errQueueFull) {
+			t.Errorf("expect queue full error, given %v", err)
+		}
+		if err = s.Write(&Entry{Status: iLog}); err == nil {
+			t.Error("expect error writing to closed sink")
+		}
+	})
+}
+
+func TestElasticsearchSink(t *testing.T) {
+	t.Parallel()
+
+	t.Run("should send ndjson bulk request", func(t *testing.T) {
+		t.Parallel()
+
+		// given
+		var lines []string
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Path != "/_bulk" || r.Header.Get("Content-Type") != "application/x-ndjson" {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			sc := bufio.NewScanner(r.Body)
+			for sc.Scan() {
+				lines = append(lines, sc.Text())
+			}
+			_, _ = w.Write([]byte(`{"errors":false,"items":[]}`))
+		}))
+		defer srv.Close()
+
+		s, _ := ElasticsearchSink(ElasticsearchConfig{URL: srv.URL, Index: "app", DailyIndex: true})
+
+		// method to test
+		_ = s.Write(&Entry{Id: "req-1", Status: iError, Info: "failed"})
+		_ = s.Close()
+
+		// assert
+		if len(lines) != 2 {
+			t.Errorf("expect action and document lines, given %v", lines)
+			t.FailNow()
+		}
+		if !strings.HasPrefix(lines[0], `{"index":{"_index":"app-`) {
+			t.Errorf("expect daily index action, given %s", lines[0])
+		}
+
+		var doc map[string]interface{}
+		if err := json.Unmarshal([]byte(lines[1]), &doc); err != nil {
+			t.Error(err.Error())
+			t.FailNow()
+		}
+		if doc["@timestamp"] == nil || doc["request_id"] != "req-1" || doc["info"] != "failed" {
+			t.Errorf("expect timestamped document, given %v", doc)
+		}
+	})
+
+	t.Run("should retry only rejected items", func(t *testing.T) {
+		t.Parallel()
+
+		// given
+		var mu sync.Mutex
+		var docs []int
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			sc := bufio.NewScanner(r.Body)
+			n := 0
+			for sc.Scan() {
+				n++
+			}
+			mu.Lock()
+			docs = append(docs, n/2)
+			first := len(docs) == 1
+			mu.Unlock()
+
+			if first {
+				_, _ = w.Write([]byte(`{"errors":true,"items":[{"index":{"status":201}},{"index":{"status":429,"error":{"type":"es_rejected_execution_exception","reason":"queue full"}}},{"index":{"status":400,"error":{"type":"mapper_parsing_exception","reason":"bad"}}}]}`))
+				return
+			}
+			_, _ = w.Write([]byte(`{"errors":false,"items":[{"index":{"status":201}}]}`))
+		}))
+		defer srv.Close()
+
+		s, _ := ElasticsearchSink(ElasticsearchConfig{URL: srv.URL, Batch: BatchConfig{Size: 3, Backoff: time.Millisecond}})
+
+		// method to test
+		for i := 0; i < 3; i++ {
+			_ = s.Write(&Entry{Status: iLog, Info: "doc"})
+		}
+		_ = s.Close()
+
+		// assert
+		mu.Lock()
+		defer mu.Unlock()
+		if len(docs) != 2 || docs[0] != 3 || docs[1] != 1 {
+			t.Errorf("expect 3 documents then 1 retried, given %v", docs)
+		}
+	})
+
+	t.Run("should report rejected items as dropped", func(t *testing.T) {
+		t.Parallel()
+
+		// given
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			_, _ = w.Write([]byte(`{"errors":true,"items":[{"index":{"status":201}},{"index":{"status":429,"error":{"type":"es_rejected_execution_exception","reason":"queue full"}}},{"index":{"status":400,"error":{"type":"mapper_parsing_exception","reason":"bad"}}}]}`))
+		}))
+		defer srv.Close()
+		sink, _ := ElasticsearchSink(ElasticsearchConfig{URL: srv.URL})
+		defer func() { _ = sink.Close() }()
+		batch := []queued{{entry: &Entry{Info: "a"}}, {entry: &Entry{Info: "b"}}, {entry: &Entry{Info: "c"}}}
+
+		// method to test
+		retry, dropped, err := sink.(*elasticsearchSink).bulk(batch)
+
+		// assert
+		if len(retry) != 1 || retry[0].entry.Info != "b" || dropped != 1 || err == nil {
+			t.Errorf("expect 1 retried and 1 dropped, given %v %d %v", retry, dropped, err)
+		}
+	})
+}
@@ -0,0 +1,148 @@
+package utils
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+)
+
+// IShutdown is implemented by the loggers returned from ProdLogger and
+// DevLogger, for services that shut down gracefully instead of through Fatal.
+type IShutdown interface {
+	OnShutdown(fn func(ctx context.Context) error)
+	Shutdown(ctx context.Context) error
+}
+
+// shutdown is shared by a logger and the copies returned by With.
+type shutdown struct {
+	timeout time.Duration
+	exit    func(code int)
+	mu      sync.Mutex
+	hooks   []func(ctx context.Context) error
+	once    sync.Once
+	err     error
+}
+
+// WithExitFunc replaces os.Exit, called by Fatal once sinks are flushed and
+// shutdown hooks have run. Tests use it to assert on Fatal without exiting.
+func WithExitFunc(fn func(code int)) Option {
+	return func(o *options) {
+		o.shutdown.exit = fn
+	}
+}
+
+// WithShutdownTimeout bounds how long Fatal waits for the entry to be
+// delivered, sinks to flush and hooks to run. Defaults to 5 seconds.
+func WithShutdownTimeout(d time.Duration) Option {
+	return func(o *options) {
+		o.shutdown.timeout = d
+	}
+}
+
+// WithShutdownHook registers fn to run, in registration order, when the logger
+// shuts down. ctx expires at the shutdown deadline.
+func WithShutdownHook(fn func(ctx context.Context) error) Option {
+	return func(o *options) {
+		o.shutdown.hooks = append(o.shutdown.hooks, fn)
+	}
+}
+
+func newShutdown() *shutdown {
+	return &shutdown{timeout: 5 * time.Second, exit: os.Exit}
+}
+
+// lazyShutdown guards lifecycle, the only writer of options.shutdown after
+// the logger is built.
+var lazyShutdown sync.Mutex
+
+// lifecycle returns the shutdown state, creating it with the defaults for a
+// Logger built as a struct literal so Fatal never dereferences nil.
+func (o *options) lifecycle() *shutdown {
+	lazyShutdown.Lock()
+	defer lazyShutdown.Unlock()
+	if o.shutdown == nil {
+		o.shutdown = newShutdown()
+	}
+	return o.shutdown
+}
+
+// OnShutdown registers fn like WithShutdownHook once the logger is built.
+func (o *options) OnShutdown(fn func(ctx context.Context) error) {
+	sd := o.lifecycle()
+	sd.mu.Lock()
+	defer sd.mu.Unlock()
+	sd.hooks = append(sd.hooks, fn)
+}
+
+// Shutdown flushes and closes every sink, then runs the shutdown hooks. Sinks
+// are closed concurrently so a slow sink does not starve the others of the
+// deadline. Only the first call does any work, later calls return its result.
+func (o *options) Shutdown(ctx context.Context) error {
+	sd := o.lifecycle()
+	sd.once.Do(func() {
+		var errs []error
+
+		done := make(chan error, len(o.sinks))
+		for _, s := range o.sinks {
+			go func(s namedSink) {
+				if err := s.sink.Close(); err != nil {
+					done <- fmt.Errorf("sink %s: %w", s.name, err)
+					return
+				}
+				done <- nil
+			}(s)
+		}
+	wait:
+		for range o.sinks {
+			select {
+			case err := <-done:
+				if err != nil {
+					errs = append(errs, err)
+				}
+			case <-ctx.Done():
+				errs = append(errs, fmt.Errorf("flushing sinks: %w", ctx.Err()))
+				break wait
+			}
+		}
+
+		sd.mu.Lock()
+		hooks := append([]func(context.Context) error{}, sd.hooks...)
+		sd.mu.Unlock()
+		for _, hook := range hooks {
+			if err := hook(ctx); err != nil {
+				errs = append(errs, err)
+			}
+		}
+
+		sd.err = errors.Join(errs...)
+	})
+	return sd.err
+}
+
+// fatal runs write, which delivers the FATAL entry to the output, webhook and
+// sinks, then shuts down and exits with status 1. Everything shares a single
+// deadline so an unreachable webhook cannot keep the process alive.
+func (o *options) fatal(write func()) {
+	sd := o.lifecycle()
+	ctx, cancel := context.WithTimeout(context.Background(), sd.timeout)
+	defer cancel()
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		write()
+	}()
+	select {
+	case <-done:
+	case <-ctx.Done():
+		o.fail("fatal entry not delivered before the shutdown deadline")
+	}
+
+	if err := o.Shutdown(ctx); err != nil {
+		o.fail("shutdown: " + err.Error())
+	}
+	sd.exit(1)
+}
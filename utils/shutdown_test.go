@@ -0,0 +1,169 @@
+package utils
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// blockingSink never finishes closing.
+type blockingSink struct{ memorySink }
+
+func (b *blockingSink) Close() error {
+	select {}
+}
+
+func TestFatal(t *testing.T) {
+	t.Parallel()
+
+	t.Run("should deliver, flush and run hooks before exiting", func(t *testing.T) {
+		t.Parallel()
+
+		// given
+		var mu sync.Mutex
+		var calls []string
+		record := func(s string) {
+			mu.Lock()
+			defer mu.Unlock()
+			calls = append(calls, s)
+		}
+
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			by, _ := io.ReadAll(r.Body)
+			switch {
+			case r.URL.Path == "/webhook" && strings.Contains(string(by), "FATAL"):
+				record("webhook")
+			case r.URL.Path == "/loki/api/v1/push":
+				record("loki " + strings.Join(strings.Fields(string(by)), ""))
+			}
+			w.WriteHeader(http.StatusNoContent)
+		}))
+		defer srv.Close()
+
+		loki, err := LokiSink(LokiConfig{URL: srv.URL, Batch: BatchConfig{Interval: time.Hour}})
+		if err != nil {
+			t.Error(err.Error())
+			t.FailNow()
+		}
+
+		code := -1
+		lg := ProdLogger(time.RFC3339, "UTC", srv.URL+"/webhook",
+			WithOutput(io.Discard),
+			WithSink("loki", loki),
+			WithShutdownHook(func(ctx context.Context) error {
+				record("first hook")
+				return nil
+			}),
+			WithExitFunc(func(c int) {
+				record("exit")
+				code = c
+			}),
+		)
+		lg.(IShutdown).OnShutdown(func(ctx context.Context) error {
+			record("second hook")
+			return nil
+		})
+
+		// method to test
+		lg.Log(context.Background(), "pending entry")
+		lg.Fatal("cannot bind port")
+
+		// assert
+		if code != 1 {
+			t.Errorf("expect exit code 1, given %d", code)
+		}
+		if len(calls) != 5 {
+			t.Errorf("expect webhook, loki flush, hooks and exit, given %v", calls)
+			t.FailNow()
+		}
+		if calls[0] != "webhook" || !strings.Contains(calls[1], "pendingentry") || !strings.Contains(calls[1], "cannotbindport") {
+			t.Errorf("expect fatal webhook then one flush with both entries, given %v", calls)
+		}
+		if calls[2] != "first hook" || calls[3] != "second hook" || calls[4] != "exit" {
+			t.Errorf("expect hooks in registration order before exit, given %v", calls)
+		}
+	})
+
+	t.Run("should exit at the deadline", func(t *testing.T) {
+		t.Parallel()
+
+		// given
+		out := new(strings.Builder)
+		exited := make(chan int, 1)
+		lg := DevLogger("UTC",
+			WithOutput(out),
+			WithSink("stuck", &blockingSink{}),
+			WithShutdownTimeout(50*time.Millisecond),
+			WithShutdownHook(func(ctx context.Context) error {
+				return ctx.Err()
+			}),
+			WithExitFunc(func(c int) { exited <- c }),
+		)
+
+		// method to test
+		go lg.Fatal("out of memory")
+
+		// assert
+		select {
+		case <-exited:
+		case <-time.After(2 * time.Second):
+			t.Error("expect exit after the shutdown deadline")
+			t.FailNow()
+		}
+		if !strings.Contains(out.String(), "out of memory") || !strings.Contains(out.String(), "flushing sinks: context deadline exceeded") {
+			t.Errorf("expect fatal entry and shutdown error in output, given %s", out.String())
+		}
+	})
+
+	t.Run("shutdown runs once", func(t *testing.T) {
+		t.Parallel()
+
+		// given
+		n := 0
+		lg := DevLogger("UTC", WithOutput(io.Discard), WithShutdownHook(func(ctx context.Context) error {
+			n++
+			return errors.New("hook failed")
+		}))
+
+		// method to test
+		first := lg.(IShutdown).Shutdown(context.Background())
+		second := lg.With(String("k", "v")).(IShutdown).Shutdown(context.Background())
+
+		// assert
+		if n != 1 || first == nil || first != second {
+			t.Errorf("expect a single run with its error returned twice, given %d %v %v", n, first, second)
+		}
+	})
+
+	t.Run("should not panic on a struct literal logger", func(t *testing.T) {
+		t.Parallel()
+
+		// given
+		lg := &Logger{TZ: time.UTC}
+		WithOutput(io.Discard)(&lg.options)
+		hooked := false
+		lg.OnShutdown(func(ctx context.Context) error {
+			hooked = true
+			return nil
+		})
+		code := -1
+		lg.lifecycle().exit = func(c int) { code = c }
+
+		// method to test
+		lg.Fatal("bye")
+
+		// assert
+		if !hooked || code != 1 {
+			t.Errorf("expect hook run and exit 1, given %v %d", hooked, code)
+		}
+		if err := (&Logger{TZ: time.UTC}).Shutdown(context.Background()); err != nil {
+			t.Errorf("expect nil shutdown error, given %v", err)
+		}
+	})
+}
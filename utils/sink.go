@@ -0,0 +1,48 @@
+package utils
+
+import (
+	"fmt"
+	"io"
+	"os"
+)
+
+// Sink receives every entry once it has been written to the logger output.
+// Implementations must be safe for concurrent use.
+type Sink interface {
+	Write(e *Entry) error
+	Close() error
+}
+
+type namedSink struct {
+	name string
+	sink Sink
+}
+
+// WithSink registers an additional destination for log entries. name is used
+// to identify the sink when it fails.
+func WithSink(name string, s Sink) Option {
+	return func(o *options) {
+		o.sinks = append(o.sinks, namedSink{name: name, sink: s})
+	}
+}
+
+// dispatch hands e to every registered sink. A failing sink never prevents
+// the remaining sinks from receiving the entry.
+func (o *options) dispatch(e *Entry) {
+	for _, s := range o.sinks {
+		if !o.levels.enabled(s.name, e.Status) {
+			continue
+		}
+		if err := s.sink.Write(e); err != nil {
+			o.fail(fmt.Sprintf("sink %s: %s", s.name, err.Error()))
+		}
+	}
+}
+
+func (o *options) fail(message string) {
+	out := o.out
+	if out == nil {
+		out = os.Stdout
+	}
+	_, _ = io.WriteString(out, string(iCritical)+" "+message+"\n")
+}
@@ -0,0 +1,322 @@
+package utils
+
+import (
+	"bytes"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	htmltemplate "html/template"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net"
+	"net/smtp"
+	"net/textproto"
+	"strconv"
+	"strings"
+	texttemplate "text/template"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// SMTPTLS controls how the connection to the mail server is secured.
+type SMTPTLS int
+
+const (
+	// StartTLSOpportunistic upgrades the connection when the server offers STARTTLS.
+	StartTLSOpportunistic SMTPTLS = iota
+	// StartTLSRequired refuses to send when the server does not offer STARTTLS.
+	StartTLSRequired
+	// ImplicitTLS dials a TLS connection straight away, usually on port 465.
+	ImplicitTLS
+	// NoTLS never upgrades the connection.
+	NoTLS
+)
+
+type SMTPConfig struct {
+	// Host and Port of the mail server. Port defaults to 587.
+	Host string
+	Port int
+	// Username and Password enable AUTH PLAIN. Go refuses to send them over an
+	// unencrypted connection unless the server is on localhost.
+	Username string
+	Password string
+	From     string
+	To       []string
+	// TLS defaults to StartTLSOpportunistic.
+	TLS SMTPTLS
+	// TLSConfig defaults to verifying Host.
+	TLSConfig *tls.Config
+	// Level is the minimum level emailed. Defaults to CRITICAL.
+	Level string
+	// SubjectPrefix defaults to [logs].
+	SubjectPrefix string
+	// HTMLTemplate and TextTemplate render an SMTPDigest. Both default to a
+	// table of entries.
+	HTMLTemplate *htmltemplate.Template
+	TextTemplate *texttemplate.Template
+	// Timeout for dialing and the whole SMTP exchange. Defaults to 10 seconds.
+	Timeout time.Duration
+	// Batch groups entries into digest emails. Interval defaults to 1 minute so
+	// an incident produces one email instead of one per entry.
+	Batch BatchConfig
+}
+
+// SMTPDigest is the data passed to the email templates.
+type SMTPDigest struct {
+	Subject string
+	Entries []*Entry
+}
+
+var defaultTextTemplate = texttemplate.Must(texttemplate.New("text").Parse(
+	`{{.Subject}}
+{{range .Entries}}
+[{{.Status}}] {{.Timestamp}} {{.Info}}
+{{- if or .Id .Path}}
+  request: {{.Id}} {{.Method}} {{.Path}} {{.Ip}}{{end}}
+{{- if .Caller}}
+  caller: {{.Caller}} {{.Function}}{{end}}
+{{- range .Fields}}
+  {{.Key}}: {{.String}}{{end}}
+{{- if .Stack}}
+{{.Stack}}{{end}}
+{{end}}`))
+
+var defaultHTMLTemplate = htmltemplate.Must(htmltemplate.New("html").Parse(
+	`<!DOCTYPE html>
+<html><body style="font-family:sans-serif">
+<h2>{{.Subject}}</h2>
+{{range .Entries}}<table style="border-collapse:collapse;margin-bottom:16px" cellpadding="4">
+<tr><th align="left">Level</th><td>{{.Status}}</td></tr>
+<tr><th align="left">Time</th><td>{{.Timestamp}}</td></tr>
+<tr><th align="left">Message</th><td>{{.Info}}</td></tr>
+{{- if or .Id .Path}}
+<tr><th align="left">Request</th><td>{{.Id}} {{.Method}} {{.Path}} {{.Ip}}</td></tr>{{end}}
+{{- if .Caller}}
+<tr><th align="left">Caller</th><td>{{.Caller}} {{.Function}}</td></tr>{{end}}
+{{- range .Fields}}
+<tr><th align="left">{{.Key}}</th><td>{{.String}}</td></tr>{{end}}
+{{- if .Stack}}
+<tr><th align="left">Stack</th><td><pre>{{.Stack}}</pre></td></tr>{{end}}
+</table>
+{{end}}</body></html>`))
+
+type smtpSink struct {
+	cfg     SMTPConfig
+	level   logType
+	batcher *batcher
+}
+
+// SMTPSink emails CRITICAL and FATAL entries as multipart HTML and plaintext
+// messages. Entries logged within Batch.Interval are sent as a single digest.
+func SMTPSink(cfg SMTPConfig) (Sink, error) {
+	if cfg.Host == "" {
+		return nil, errors.New("smtp sink: host is required")
+	}
+	if cfg.From == "" || len(cfg.To) == 0 {
+		return nil, errors.New("smtp sink: from and to are required")
+	}
+	if cfg.Port == 0 {
+		cfg.Port = 587
+	}
+	if cfg.Level == "" {
+		cfg.Level = string(iCritical)
+	}
+	level, err := parseLevel(cfg.Level)
+	if err != nil {
+		return nil, fmt.Errorf("smtp sink: %w", err)
+	}
+	if cfg.SubjectPrefix == "" {
+		cfg.SubjectPrefix = "[logs]"
+	}
+	if cfg.HTMLTemplate == nil {
+		cfg.HTMLTemplate = defaultHTMLTemplate
+	}
+	if cfg.TextTemplate == nil {
+		cfg.TextTemplate = defaultTextTemplate
+	}
+	if cfg.TLSConfig == nil {
+		cfg.TLSConfig = &tls.Config{ServerName: cfg.Host}
+	}
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = 10 * time.Second
+	}
+	if cfg.Batch.Interval <= 0 {
+		cfg.Batch.Interval = time.Minute
+	}
+
+	s := &smtpSink{cfg: cfg, level: level}
+	s.batcher = newBatcher("smtp sink", cfg.Batch, s.send)
+	return s, nil
+}
+
+func (s *smtpSink) Write(e *Entry) error {
+	if e.Status.rank() < s.level.rank() {
+		return nil
+	}
+	return s.batcher.add(e)
+}
+
+func (s *smtpSink) Close() error {
+	s.batcher.close()
+	return nil
+}
+
+// subject names the most severe level and its message. Line breaks are
+// dropped as they would end the header.
+func (s *smtpSink) subject(entries []*Entry) string {
+	top := entries[0]
+	for _, e := range entries[1:] {
+		if e.Status.rank() > top.Status.rank() {
+			top = e
+		}
+	}
+	info := strings.Join(strings.Fields(top.Info), " ")
+	if len(entries) == 1 {
+		return fmt.Sprintf("%s %s: %s", s.cfg.SubjectPrefix, top.Status, info)
+	}
+	return fmt.Sprintf("%s %d %s alerts: %s", s.cfg.SubjectPrefix, len(entries), top.Status, info)
+}
+
+// message renders a multipart/alternative email for entries.
+func (s *smtpSink) message(entries []*Entry, now time.Time) ([]byte, error) {
+	digest := SMTPDigest{Subject: s.subject(entries), Entries: entries}
+
+	var text, html bytes.Buffer
+	if err := s.cfg.TextTemplate.Execute(&text, digest); err != nil {
+		return nil, err
+	}
+	if err := s.cfg.HTMLTemplate.Execute(&html, digest); err != nil {
+		return nil, err
+	}
+
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	for _, part := range []struct {
+		contentType string
+		content     []byte
+	}{
+		{"text/plain; charset=utf-8", text.Bytes()},
+		{"text/html; charset=utf-8", html.Bytes()},
+	} {
+		w, err := mw.CreatePart(textproto.MIMEHeader{
+			"Content-Type":              {part.contentType},
+			"Content-Transfer-Encoding": {"quoted-printable"},
+		})
+		if err != nil {
+			return nil, err
+		}
+		qp := quotedprintable.NewWriter(w)
+		if _, err = qp.Write(part.content); err != nil {
+			return nil, err
+		}
+		if err = qp.Close(); err != nil {
+			return nil, err
+		}
+	}
+	if err := mw.Close(); err != nil {
+		return nil, err
+	}
+
+	var msg bytes.Buffer
+	headers := [][2]string{
+		{"From", s.cfg.From},
+		{"To", strings.Join(s.cfg.To, ", ")},
+		{"Subject", mime.QEncoding.Encode("utf-8", digest.Subject)},
+		{"Date", now.Format(time.RFC1123Z)},
+		{"Message-ID", "<" + uuid.NewString() + "@" + s.cfg.Host + ">"},
+		{"MIME-Version", "1.0"},
+		{"Content-Type", `multipart/alternative; boundary="` + mw.Boundary() + `"`},
+	}
+	for _, h := range headers {
+		msg.WriteString(h[0] + ": " + h[1] + "\r\n")
+	}
+	msg.WriteString("\r\n")
+	msg.Write(body.Bytes())
+	return msg.Bytes(), nil
+}
+
+func (s *smtpSink) send(batch []queued) ([]queued, int, error) {
+	entries := make([]*Entry, 0, len(batch))
+	for _, q := range batch {
+		entries = append(entries, q.entry)
+	}
+	msg, err := s.message(entries, time.Now())
+	if err != nil {
+		return nil, len(batch), err
+	}
+
+	if err = s.deliver(msg); err != nil {
+		// 5xx replies are permanent e.g. a rejected recipient or failed auth
+		var te *textproto.Error
+		if errors.As(err, &te) && te.Code >= 500 {
+			return nil, len(batch), err
+		}
+		return batch, 0, err
+	}
+	return nil, 0, nil
+}
+
+func (s *smtpSink) deliver(msg []byte) error {
+	addr := net.JoinHostPort(s.cfg.Host, strconv.Itoa(s.cfg.Port))
+	dialer := &net.Dialer{Timeout: s.cfg.Timeout}
+
+	var conn net.Conn
+	var err error
+	if s.cfg.TLS == ImplicitTLS {
+		conn, err = tls.DialWithDialer(dialer, "tcp", addr, s.cfg.TLSConfig)
+	} else {
+		conn, err = dialer.Dial("tcp", addr)
+	}
+	if err != nil {
+		return err
+	}
+	if err = conn.SetDeadline(time.Now().Add(s.cfg.Timeout)); err != nil {
+		_ = conn.Close()
+		return err
+	}
+
+	c, err := smtp.NewClient(conn, s.cfg.Host)
+	if err != nil {
+		_ = conn.Close()
+		return err
+	}
+	defer func() { _ = c.Close() }()
+
+	if s.cfg.TLS == StartTLSOpportunistic || s.cfg.TLS == StartTLSRequired {
+		if ok, _ := c.Extension("STARTTLS"); ok {
+			if err = c.StartTLS(s.cfg.TLSConfig); err != nil {
+				return err
+			}
+		} else if s.cfg.TLS == StartTLSRequired {
+			return errors.New("server does not support STARTTLS")
+		}
+	}
+
+	if s.cfg.Username != "" {
+		if err = c.Auth(smtp.PlainAuth("", s.cfg.Username, s.cfg.Password, s.cfg.Host)); err != nil {
+			return err
+		}
+	}
+
+	if err = c.Mail(s.cfg.From); err != nil {
+		return err
+	}
+	for _, to := range s.cfg.To {
+		if err = c.Rcpt(to); err != nil {
+			return err
+		}
+	}
+	w, err := c.Data()
+	if err != nil {
+		return err
+	}
+	if _, err = w.Write(msg); err != nil {
+		return err
+	}
+	if err = w.Close(); err != nil {
+		return err
+	}
+	return c.Quit()
+}
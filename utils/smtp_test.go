@@ -0,0 +1,321 @@
+package utils
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"io"
+	"math/big"
+	"mime"
+	"mime/multipart"
+	"net"
+	"net/mail"
+	"net/textproto"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+type received struct {
+	tls  bool
+	auth string
+	from string
+	to   []string
+	data string
+}
+
+// smtpStandIn is a minimal in-process SMTP server supporting EHLO, STARTTLS,
+// AUTH PLAIN, MAIL, RCPT and DATA.
+type smtpStandIn struct {
+	ln     net.Listener
+	tls    *tls.Config
+	reject string
+	mu     sync.Mutex
+	mails  []received
+}
+
+func newSMTPStandIn(t *testing.T, startTLS bool) *smtpStandIn {
+	t.Helper()
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Error(err.Error())
+		t.FailNow()
+	}
+	s := &smtpStandIn{ln: ln}
+	if startTLS {
+		s.tls = selfSigned(t)
+	}
+	t.Cleanup(func() { _ = ln.Close() })
+
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go s.serve(conn)
+		}
+	}()
+	return s
+}
+
+func (s *smtpStandIn) port() int {
+	return s.ln.Addr().(*net.TCPAddr).Port
+}
+
+func (s *smtpStandIn) all() []received {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]received(nil), s.mails...)
+}
+
+func (s *smtpStandIn) serve(conn net.Conn) {
+	defer func() { _ = conn.Close() }()
+
+	tp := textproto.NewConn(conn)
+	var m received
+	_ = tp.PrintfLine("220 localhost ready")
+	for {
+		line, err := tp.ReadLine()
+		if err != nil {
+			return
+		}
+		cmd, arg, _ := strings.Cut(line, " ")
+		switch strings.ToUpper(cmd) {
+		case "EHLO":
+			ext := "250-localhost\r\n"
+			if s.tls != nil && !m.tls {
+				ext += "250-STARTTLS\r\n"
+			}
+			_ = tp.PrintfLine("%s250 AUTH PLAIN", ext)
+		case "STARTTLS":
+			_ = tp.PrintfLine("220 go ahead")
+			tc := tls.Server(conn, s.tls)
+			if err = tc.Handshake(); err != nil {
+				return
+			}
+			conn = tc
+			tp = textproto.NewConn(tc)
+			m.tls = true
+		case "AUTH":
+			by, _ := base64.StdEncoding.DecodeString(strings.TrimPrefix(arg, "PLAIN "))
+			m.auth = string(by)
+			_ = tp.PrintfLine("235 authenticated")
+		case "MAIL":
+			m.from = arg
+			_ = tp.PrintfLine("250 ok")
+		case "RCPT":
+			if s.reject != "" && strings.Contains(arg, s.reject) {
+				_ = tp.PrintfLine("550 no such user")
+				continue
+			}
+			m.to = append(m.to, arg)
+			_ = tp.PrintfLine("250 ok")
+		case "DATA":
+			_ = tp.PrintfLine("354 end with .")
+			by, err := tp.ReadDotBytes()
+			if err != nil {
+				return
+			}
+			m.data = string(by)
+			s.mu.Lock()
+			s.mails = append(s.mails, m)
+			s.mu.Unlock()
+			_ = tp.PrintfLine("250 queued")
+		case "QUIT":
+			_ = tp.PrintfLine("221 bye")
+			return
+		default:
+			_ = tp.PrintfLine("250 ok")
+		}
+	}
+}
+
+func selfSigned(t *testing.T) *tls.Config {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Error(err.Error())
+		t.FailNow()
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "127.0.0.1"},
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Error(err.Error())
+		t.FailNow()
+	}
+	return &tls.Config{Certificates: []tls.Certificate{{Certificate: [][]byte{der}, PrivateKey: key}}}
+}
+
+// parts decodes the plaintext and html bodies of a multipart/alternative email.
+func parts(t *testing.T, data string) (*mail.Message, map[string]string) {
+	t.Helper()
+
+	msg, err := mail.ReadMessage(strings.NewReader(data))
+	if err != nil {
+		t.Error(err.Error())
+		t.FailNow()
+	}
+	_, params, err := mime.ParseMediaType(msg.Header.Get("Content-Type"))
+	if err != nil {
+		t.Error(err.Error())
+		t.FailNow()
+	}
+
+	res := map[string]string{}
+	mr := multipart.NewReader(msg.Body, params["boundary"])
+	for {
+		p, err := mr.NextPart()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Error(err.Error())
+			t.FailNow()
+		}
+		by, _ := io.ReadAll(p)
+		mediaType, _, _ := mime.ParseMediaType(p.Header.Get("Content-Type"))
+		res[mediaType] = string(by)
+	}
+	return msg, res
+}
+
+func TestSMTPSink(t *testing.T) {
+	t.Parallel()
+
+	t.Run("should send one digest over starttls", func(t *testing.T) {
+		t.Parallel()
+
+		// given
+		srv := newSMTPStandIn(t, true)
+		sink, err := SMTPSink(SMTPConfig{
+			Host:      "127.0.0.1",
+			Port:      srv.port(),
+			Username:  "alerts",
+			Password:  "secret",
+			From:      "logs@example.com",
+			To:        []string{"oncall@example.com", "lead@example.com"},
+			TLS:       StartTLSRequired,
+			TLSConfig: &tls.Config{InsecureSkipVerify: true},
+			Batch:     BatchConfig{Interval: time.Hour},
+		})
+		if err != nil {
+			t.Error(err.Error())
+			t.FailNow()
+		}
+
+		// method to test
+		_ = sink.Write(&Entry{Status: iCritical, Info: "database down", Path: "/api/orders", Fields: []Field{Int("attempt", 3)}})
+		_ = sink.Write(&Entry{Status: iError, Info: "validation failed"})
+		_ = sink.Write(&Entry{Status: iFatal, Info: "<shutting down>"})
+		_ = sink.Close()
+
+		// assert
+		mails := srv.all()
+		if len(mails) != 1 {
+			t.Errorf("expect 1 digest, given %d", len(mails))
+			t.FailNow()
+		}
+		m := mails[0]
+		if !m.tls || m.auth != "\x00alerts\x00secret" {
+			t.Errorf("expect authenticated tls session, given tls %v auth %q", m.tls, m.auth)
+		}
+		if len(m.to) != 2 {
+			t.Errorf("expect 2 recipients, given %v", m.to)
+		}
+
+		msg, bodies := parts(t, m.data)
+		if subject := msg.Header.Get("Subject"); subject != "[logs] 2 FATAL alerts: <shutting down>" {
+			t.Errorf("expect digest subject, given %s", subject)
+		}
+		text := bodies["text/plain"]
+		if !strings.Contains(text, "[CRITICAL]") || !strings.Contains(text, "attempt: 3") || strings.Contains(text, "validation failed") {
+			t.Errorf("expect CRITICAL and FATAL entries in plaintext, given %s", text)
+		}
+		if html := bodies["text/html"]; !strings.Contains(html, "&lt;shutting down&gt;") || !strings.Contains(html, "/api/orders") {
+			t.Errorf("expect escaped entries in html, given %s", html)
+		}
+	})
+
+	t.Run("should refuse plaintext when starttls is required", func(t *testing.T) {
+		t.Parallel()
+
+		// given
+		srv := newSMTPStandIn(t, false)
+		sink, _ := SMTPSink(SMTPConfig{
+			Host: "127.0.0.1",
+			Port: srv.port(),
+			From: "logs@example.com",
+			To:   []string{"oncall@example.com"},
+			TLS:  StartTLSRequired,
+		})
+		defer func() { _ = sink.Close() }()
+
+		// method to test
+		err := sink.(*smtpSink).deliver([]byte("Subject: test\r\n\r\nbody"))
+
+		// assert
+		if err == nil || !strings.Contains(err.Error(), "STARTTLS") {
+			t.Errorf("expect STARTTLS error, given %v", err)
+		}
+		if n := len(srv.all()); n != 0 {
+			t.Errorf("expect no mail, given %d", n)
+		}
+	})
+
+	t.Run("should not retry permanent failures", func(t *testing.T) {
+		t.Parallel()
+
+		// given
+		srv := newSMTPStandIn(t, false)
+		srv.reject = "nobody"
+		sink, _ := SMTPSink(SMTPConfig{
+			Host: "127.0.0.1",
+			Port: srv.port(),
+			From: "logs@example.com",
+			To:   []string{"nobody@example.com"},
+			TLS:  NoTLS,
+		})
+		defer func() { _ = sink.Close() }()
+
+		// method to test
+		retry, dropped, err := sink.(*smtpSink).send([]queued{{entry: &Entry{Status: iCritical, Info: "down"}}})
+
+		// assert
+		if err == nil || retry != nil || dropped != 1 {
+			t.Errorf("expect permanent error dropping the entry, given %v %d %v", retry, dropped, err)
+		}
+		if !strings.Contains(err.Error(), strconv.Itoa(550)) {
+			t.Errorf("expect 550 reply, given %s", err.Error())
+		}
+	})
+
+	t.Run("should validate config", func(t *testing.T) {
+		t.Parallel()
+
+		for _, cfg := range []SMTPConfig{
+			{From: "a@example.com", To: []string{"b@example.com"}},
+			{Host: "localhost", To: []string{"b@example.com"}},
+			{Host: "localhost", From: "a@example.com", To: []string{"b@example.com"}, Level: "verbose"},
+		} {
+			// method to test
+			if _, err := SMTPSink(cfg); err == nil {
+				t.Errorf("expect error for %+v", cfg)
+			}
+		}
+	})
+}
@@ -0,0 +1,246 @@
+package utils
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// sdID is the structured data id used for request and field parameters.
+// 32473 is the private enterprise number reserved for documentation (RFC 5612).
+const sdID = "32473"
+
+// nilValue is the RFC 5424 placeholder for an empty header part.
+const nilValue = "-"
+
+// syslog severities as defined in RFC 5424 section 6.2.1
+const (
+	severityAlert    = 1
+	severityCritical = 2
+	severityError    = 3
+	severityInfo     = 6
+	severityDebug    = 7
+)
+
+// severity maps a logType to its syslog severity.
+func severity(t logType) int {
+	switch t {
+	case iFatal:
+		return severityAlert
+	case iCritical:
+		return severityCritical
+	case iError:
+		return severityError
+	case iDebug:
+		return severityDebug
+	default:
+		return severityInfo
+	}
+}
+
+type SyslogConfig struct {
+	// Network is one of udp, tcp, unix or unixgram. Defaults to unixgram.
+	Network string
+	// Address of the syslog daemon. Defaults to /dev/log for unix sockets.
+	Address string
+	// AppName identifies the service. Defaults to the executable name.
+	AppName string
+	// Hostname defaults to os.Hostname.
+	Hostname string
+	// Facility defaults to 1 (user-level messages).
+	Facility int
+	// Timeout for dialing and writing. Defaults to 2 seconds.
+	Timeout time.Duration
+}
+
+type syslogSink struct {
+	cfg    SyslogConfig
+	pid    int
+	mu     sync.Mutex
+	conn   net.Conn
+	closed bool
+}
+
+// SyslogSink writes RFC 5424 messages to a local or remote syslog daemon.
+// Messages over tcp and stream unix sockets use octet-counting framing
+// (RFC 6587). The request id, ip, method and path are sent as structured data.
+func SyslogSink(cfg SyslogConfig) (Sink, error) {
+	if cfg.Network == "" {
+		cfg.Network = "unixgram"
+	}
+	if cfg.Address == "" {
+		if cfg.Network != "unix" && cfg.Network != "unixgram" {
+			return nil, errors.New("syslog sink: address is required")
+		}
+		cfg.Address = "/dev/log"
+	}
+	if cfg.AppName == "" {
+		cfg.AppName = nilValue
+		if exe, err := os.Executable(); err == nil {
+			cfg.AppName = exe[strings.LastIndex(exe, "/")+1:]
+		}
+	}
+	if cfg.Hostname == "" {
+		cfg.Hostname = nilValue
+		if h, err := os.Hostname(); err == nil {
+			cfg.Hostname = h
+		}
+	}
+	if cfg.Facility == 0 {
+		cfg.Facility = 1
+	}
+	if cfg.Timeout == 0 {
+		cfg.Timeout = 2 * time.Second
+	}
+
+	s := &syslogSink{cfg: cfg, pid: os.Getpid()}
+	if err := s.connect(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+func (s *syslogSink) connect() error {
+	conn, err := net.DialTimeout(s.cfg.Network, s.cfg.Address, s.cfg.Timeout)
+	if err != nil {
+		return err
+	}
+	s.conn = conn
+	return nil
+}
+
+func (s *syslogSink) stream() bool {
+	return s.cfg.Network == "tcp" || s.cfg.Network == "unix"
+}
+
+func (s *syslogSink) Write(e *Entry) error {
+	msg := s.format(e, entryTime(e))
+	if s.stream() {
+		msg = fmt.Sprintf("%d %s", len(msg), msg)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.closed {
+		return errors.New("syslog sink: closed")
+	}
+	if s.conn == nil {
+		if err := s.connect(); err != nil {
+			return err
+		}
+	}
+
+	// a daemon restart breaks the connection so retry once on a fresh one
+	if err := s.send(msg); err != nil {
+		_ = s.conn.Close()
+		s.conn = nil
+		if err = s.connect(); err != nil {
+			return err
+		}
+		return s.send(msg)
+	}
+	return nil
+}
+
+func (s *syslogSink) send(msg string) error {
+	if err := s.conn.SetWriteDeadline(time.Now().Add(s.cfg.Timeout)); err != nil {
+		return err
+	}
+	_, err := s.conn.Write([]byte(msg))
+	return err
+}
+
+// format renders e as <PRI>1 TIMESTAMP HOSTNAME APP-NAME PROCID MSGID SD MSG
+func (s *syslogSink) format(e *Entry, now time.Time) string {
+	pri := s.cfg.Facility*8 + severity(e.Status)
+
+	var sd strings.Builder
+	params := [][2]string{{"id", e.Id}, {"trace_id", e.TraceId}, {"span_id", e.SpanId}, {"ip", e.Ip}, {"method", e.Method}, {"path", e.Path}}
+	sd.WriteString(sdElement("request@"+sdID, params))
+	if len(e.Fields) > 0 {
+		fields := make([][2]string, 0, len(e.Fields))
+		for _, f := range e.Fields {
+			fields = append(fields, [2]string{f.Key, f.String()})
+		}
+		sd.WriteString(sdElement("fields@"+sdID, fields))
+	}
+	if sd.Len() == 0 {
+		sd.WriteString(nilValue)
+	}
+
+	return fmt.Sprintf("<%d>1 %s %s %s %d %s %s %s",
+		pri,
+		now.Format("2006-01-02T15:04:05.000000Z07:00"),
+		header(s.cfg.Hostname, 255),
+		header(s.cfg.AppName, 48),
+		s.pid,
+		header(string(e.Status), 32),
+		sd.String(),
+		e.Info,
+	)
+}
+
+// header keeps printable US-ASCII as required for header parts.
+func header(s string, max int) string {
+	s = strings.Map(func(r rune) rune {
+		if r < 33 || r > 126 {
+			return -1
+		}
+		return r
+	}, s)
+	if s == "" {
+		return nilValue
+	}
+	if len(s) > max {
+		return s[:max]
+	}
+	return s
+}
+
+// sdName drops characters not allowed in an SD-NAME.
+func sdName(s string) string {
+	s = strings.Map(func(r rune) rune {
+		if r < 33 || r > 126 || r == '=' || r == ']' || r == '"' || r == ' ' {
+			return -1
+		}
+		return r
+	}, s)
+	if len(s) > 32 {
+		return s[:32]
+	}
+	return s
+}
+
+func sdElement(id string, params [][2]string) string {
+	var sb strings.Builder
+	for _, p := range params {
+		name := sdName(p[0])
+		if name == "" || p[1] == "" {
+			continue
+		}
+		sb.WriteString(" " + name + `="`)
+		sb.WriteString(strings.NewReplacer(`\`, `\\`, `"`, `\"`, `]`, `\]`).Replace(p[1]))
+		sb.WriteString(`"`)
+	}
+	if sb.Len() == 0 {
+		return ""
+	}
+	return "[" + id + sb.String() + "]"
+}
+
+func (s *syslogSink) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.closed = true
+	if s.conn == nil {
+		return nil
+	}
+	err := s.conn.Close()
+	s.conn = nil
+	return err
+}
@@ -0,0 +1,219 @@
+package utils
+
+import (
+	"bufio"
+	"net"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+var rfc5424 = regexp.MustCompile(`^<(\d+)>1 \S+ \S+ (\S+) \d+ (\S+) (\[.*\]|-) (.*)$`)
+
+func TestSyslogSink(t *testing.T) {
+	t.Parallel()
+
+	entry := &Entry{
+		Id:     "req-1",
+		Method: "GET",
+		Path:   `/api/"quoted"]`,
+		Status: iCritical,
+		Info:   "database unreachable",
+		Fields: []Field{Int("attempt", 3)},
+	}
+
+	assertMessage := func(t *testing.T, msg string) {
+		t.Helper()
+
+		m := rfc5424.FindStringSubmatch(msg)
+		if m == nil {
+			t.Errorf("expect RFC 5424 message, given %s", msg)
+			t.FailNow()
+		}
+
+		// facility local0 (16) * 8 + critical (2)
+		if m[1] != "130" {
+			t.Errorf("expect priority 130, given %s", m[1])
+		}
+		if m[2] != "api" || m[3] != "CRITICAL" {
+			t.Errorf("expect app name api and msgid CRITICAL, given %s %s", m[2], m[3])
+		}
+		sd := `[request@32473 id="req-1" method="GET" path="/api/\"quoted\"\]"][fields@32473 attempt="3"]`
+		if m[4] != sd {
+			t.Errorf("expect structured data %s, given %s", sd, m[4])
+		}
+		if m[5] != entry.Info {
+			t.Errorf("expect message %s, given %s", entry.Info, m[5])
+		}
+	}
+
+	t.Run("udp", func(t *testing.T) {
+		t.Parallel()
+
+		// given
+		pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+		if err != nil {
+			t.Error(err.Error())
+			t.FailNow()
+		}
+		defer pc.Close()
+
+		s, err := SyslogSink(SyslogConfig{Network: "udp", Address: pc.LocalAddr().String(), AppName: "api", Facility: 16})
+		if err != nil {
+			t.Error(err.Error())
+			t.FailNow()
+		}
+		defer s.Close()
+
+		// method to test
+		if err = s.Write(entry); err != nil {
+			t.Error(err.Error())
+			t.FailNow()
+		}
+
+		// assert
+		buf := make([]byte, 4096)
+		_ = pc.SetReadDeadline(time.Now().Add(2 * time.Second))
+		n, _, err := pc.ReadFrom(buf)
+		if err != nil {
+			t.Error(err.Error())
+			t.FailNow()
+		}
+		assertMessage(t, string(buf[:n]))
+	})
+
+	t.Run("should not reconnect after close", func(t *testing.T) {
+		t.Parallel()
+
+		// given
+		pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+		if err != nil {
+			t.Error(err.Error())
+			t.FailNow()
+		}
+		defer pc.Close()
+
+		s, err := SyslogSink(SyslogConfig{Network: "udp", Address: pc.LocalAddr().String()})
+		if err != nil {
+			t.Error(err.Error())
+			t.FailNow()
+		}
+		if err = s.Close(); err != nil {
+			t.Error(err.Error())
+			t.FailNow()
+		}
+
+		// method to test
+		err = s.Write(entry)
+
+		// assert
+		if err == nil {
+			t.Errorf("expect error writing to a closed sink")
+		}
+		if conn := s.(*syslogSink).conn; conn != nil {
+			t.Errorf("expect no connection after close, given %v", conn.LocalAddr())
+		}
+	})
+
+	t.Run("tcp uses octet counting", func(t *testing.T) {
+		t.Parallel()
+
+		// given
+		ln, err := net.Listen("tcp", "127.0.0.1:0")
+		if err != nil {
+			t.Error(err.Error())
+			t.FailNow()
+		}
+		defer ln.Close()
+
+		received := make(chan string, 1)
+		go func() {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			defer conn.Close()
+			r := bufio.NewReader(conn)
+			size, err := r.ReadString(' ')
+			if err != nil {
+				return
+			}
+			n, _ := strconv.Atoi(strings.TrimSpace(size))
+			buf := make([]byte, n)
+			if _, err = r.Read(buf); err == nil {
+				received <- string(buf)
+			}
+		}()
+
+		s, err := SyslogSink(SyslogConfig{Network: "tcp", Address: ln.Addr().String(), AppName: "api", Facility: 16})
+		if err != nil {
+			t.Error(err.Error())
+			t.FailNow()
+		}
+		defer s.Close()
+
+		// method to test
+		if err = s.Write(entry); err != nil {
+			t.Error(err.Error())
+			t.FailNow()
+		}
+
+		// assert
+		select {
+		case msg := <-received:
+			assertMessage(t, msg)
+		case <-time.After(2 * time.Second):
+			t.Error("expect message, given timeout")
+		}
+	})
+
+	t.Run("unix datagram", func(t *testing.T) {
+		t.Parallel()
+
+		// given
+		addr := filepath.Join(t.TempDir(), "log.sock")
+		pc, err := net.ListenPacket("unixgram", addr)
+		if err != nil {
+			t.Error(err.Error())
+			t.FailNow()
+		}
+		defer pc.Close()
+
+		s, err := SyslogSink(SyslogConfig{Address: addr, AppName: "api", Facility: 16})
+		if err != nil {
+			t.Error(err.Error())
+			t.FailNow()
+		}
+		defer s.Close()
+
+		// method to test
+		if err = s.Write(entry); err != nil {
+			t.Error(err.Error())
+			t.FailNow()
+		}
+
+		// assert
+		buf := make([]byte, 4096)
+		_ = pc.SetReadDeadline(time.Now().Add(2 * time.Second))
+		n, _, err := pc.ReadFrom(buf)
+		if err != nil {
+			t.Error(err.Error())
+			t.FailNow()
+		}
+		assertMessage(t, string(buf[:n]))
+	})
+
+	t.Run("severity mapping", func(t *testing.T) {
+		t.Parallel()
+
+		expect := map[logType]int{iLog: 6, iError: 3, iCritical: 2, iFatal: 1}
+		for level, sev := range expect {
+			if severity(level) != sev {
+				t.Errorf("expect %s to map to %d, given %d", level, sev, severity(level))
+			}
+		}
+	})
+}
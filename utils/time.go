@@ -0,0 +1,68 @@
+package utils
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// UnixMillis renders timestamps as milliseconds since the Unix epoch, a number
+// in JSON and a plain integer in text encoders.
+const UnixMillis = "unixmillis"
+
+// defaultTimeFormat keeps the full precision of every entry.
+const defaultTimeFormat = time.RFC3339Nano
+
+// WithTimeFormat sets the layout timestamps are rendered with, a time layout
+// such as time.RFC3339Nano or UnixMillis. ProdLogger applies its timeformat
+// argument through this option, DevLogger defaults to time.RFC3339Nano.
+func WithTimeFormat(layout string) Option {
+	return func(o *options) {
+		o.timeFormat = layout
+	}
+}
+
+// validTimeFormat rejects layouts that contain no time elements, which Go
+// would otherwise render as the literal layout string.
+func validTimeFormat(layout string) error {
+	if layout == "" || layout == UnixMillis {
+		return nil
+	}
+	// any time other than the reference time, which formats to the layout itself
+	ref := time.Date(2001, 2, 3, 4, 5, 6, 123456789, time.UTC)
+	s := ref.Format(layout)
+	if s == layout {
+		return fmt.Errorf("time format %q contains no time elements", layout)
+	}
+	if _, err := time.Parse(layout, s); err != nil {
+		return errors.Join(fmt.Errorf("invalid time format %q", layout), err)
+	}
+	return nil
+}
+
+// formatTime renders t with layout, as an int64 for UnixMillis.
+func formatTime(t time.Time, layout string) interface{} {
+	switch layout {
+	case "":
+		return t.Format(defaultTimeFormat)
+	case UnixMillis:
+		return t.UnixMilli()
+	default:
+		return t.Format(layout)
+	}
+}
+
+// Timestamp renders the entry time with the layout of the logger that
+// created it.
+func (e *Entry) Timestamp() string {
+	return fmt.Sprint(formatTime(e.Time, e.timeFormat))
+}
+
+// entryTime is when e was logged, falling back to now for entries built
+// without a time.
+func entryTime(e *Entry) time.Time {
+	if e.Time.IsZero() {
+		return time.Now()
+	}
+	return e.Time
+}
@@ -0,0 +1,401 @@
+package utils
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// TraceID and SpanID are W3C Trace Context identifiers, all zero is invalid.
+type TraceID [16]byte
+type SpanID [8]byte
+
+func (t TraceID) String() string { return hex.EncodeToString(t[:]) }
+func (t TraceID) IsValid() bool  { return t != TraceID{} }
+func (s SpanID) String() string  { return hex.EncodeToString(s[:]) }
+func (s SpanID) IsValid() bool   { return s != SpanID{} }
+
+// flagSampled is the only trace flag defined by W3C Trace Context.
+const flagSampled byte = 0x01
+
+// SpanContext is the part of a span propagated across services.
+type SpanContext struct {
+	TraceID TraceID
+	SpanID  SpanID
+	Flags   byte
+	// TraceState is the vendor specific tracestate header, passed on as is.
+	TraceState string
+	// Remote is true when the context was received from another service.
+	Remote bool
+}
+
+func (sc SpanContext) IsValid() bool {
+	return sc.TraceID.IsValid() && sc.SpanID.IsValid()
+}
+
+func (sc SpanContext) Sampled() bool {
+	return sc.Flags&flagSampled != 0
+}
+
+// Traceparent renders sc as a version 00 traceparent header.
+func (sc SpanContext) Traceparent() string {
+	return fmt.Sprintf("00-%s-%s-%02x", sc.TraceID, sc.SpanID, sc.Flags)
+}
+
+// ParseTraceparent parses a traceparent header. Versions above 00 are parsed
+// by their version 00 prefix as required by the specification.
+func ParseTraceparent(h string) (SpanContext, error) {
+	var sc SpanContext
+	h = strings.TrimSpace(h)
+	if len(h) < 55 || (len(h) > 55 && (h[:2] == "00" || h[55] != '-')) {
+		return sc, errors.New("traceparent: invalid length")
+	}
+	if h[2] != '-' || h[35] != '-' || h[52] != '-' {
+		return sc, errors.New("traceparent: invalid format")
+	}
+	version, err := lowerHex(h[:2], 1)
+	if err != nil || version[0] == 0xff {
+		return sc, errors.New("traceparent: invalid version")
+	}
+	trace, err := lowerHex(h[3:35], 16)
+	if err != nil {
+		return sc, errors.New("traceparent: invalid trace id")
+	}
+	span, err := lowerHex(h[36:52], 8)
+	if err != nil {
+		return sc, errors.New("traceparent: invalid parent id")
+	}
+	flags, err := lowerHex(h[53:55], 1)
+	if err != nil {
+		return sc, errors.New("traceparent: invalid flags")
+	}
+
+	copy(sc.TraceID[:], trace)
+	copy(sc.SpanID[:], span)
+	sc.Flags = flags[0]
+	if !sc.IsValid() {
+		return SpanContext{}, errors.New("traceparent: all zero id")
+	}
+	return sc, nil
+}
+
+// lowerHex decodes s, rejecting upper case as the specification does.
+func lowerHex(s string, n int) ([]byte, error) {
+	if len(s) != n*2 || strings.ToLower(s) != s {
+		return nil, errors.New("invalid hex")
+	}
+	return hex.DecodeString(s)
+}
+
+// validTraceState keeps tracestate headers within the 32 list members and
+// 512 characters allowed by the specification.
+func validTraceState(s string) bool {
+	if len(s) > 512 {
+		return false
+	}
+	members := 0
+	for _, m := range strings.Split(s, ",") {
+		m = strings.TrimSpace(m)
+		if m == "" {
+			continue
+		}
+		if k, v, ok := strings.Cut(m, "="); !ok || k == "" || v == "" {
+			return false
+		}
+		members++
+	}
+	return members <= 32
+}
+
+// Extract reads the traceparent and tracestate headers sent by a caller.
+func Extract(h http.Header) (SpanContext, bool) {
+	sc, err := ParseTraceparent(h.Get("traceparent"))
+	if err != nil {
+		return SpanContext{}, false
+	}
+	if ts := strings.Join(h.Values("tracestate"), ","); ts != "" && validTraceState(ts) {
+		sc.TraceState = ts
+	}
+	sc.Remote = true
+	return sc, true
+}
+
+// Inject writes the span in ctx as traceparent and tracestate headers for an
+// outgoing request.
+func Inject(ctx context.Context, h http.Header) {
+	s := SpanFromContext(ctx)
+	if s == nil || !s.sc.IsValid() {
+		return
+	}
+	h.Set("traceparent", s.sc.Traceparent())
+	if s.sc.TraceState != "" {
+		h.Set("tracestate", s.sc.TraceState)
+	}
+}
+
+type SpanKind int
+
+// span kinds as numbered by OTLP
+const (
+	SpanKindInternal SpanKind = 1
+	SpanKindServer   SpanKind = 2
+	SpanKindClient   SpanKind = 3
+)
+
+type SpanStatus int
+
+// span status codes as numbered by OTLP
+const (
+	StatusUnset SpanStatus = 0
+	StatusOK    SpanStatus = 1
+	StatusError SpanStatus = 2
+)
+
+// SpanData is a snapshot of an ended span handed to a SpanExporter.
+type SpanData struct {
+	Service       string
+	Name          string
+	Kind          SpanKind
+	SpanContext   SpanContext
+	Parent        SpanID
+	Start         time.Time
+	End           time.Time
+	Attributes    []Field
+	Status        SpanStatus
+	StatusMessage string
+}
+
+// SpanExporter sends ended spans to a tracing backend.
+type SpanExporter interface {
+	Export(ctx context.Context, spans []SpanData) error
+	Shutdown(ctx context.Context) error
+}
+
+// Span is a timed operation. Its methods are safe for concurrent use and do
+// nothing once End was called.
+type Span struct {
+	tracer *tracer
+	sc     SpanContext
+	mu     sync.Mutex
+	data   SpanData
+	ended  bool
+}
+
+type spanContextKey struct{}
+
+// SpanFromContext returns the current span or nil.
+func SpanFromContext(ctx context.Context) *Span {
+	s, _ := ctx.Value(spanContextKey{}).(*Span)
+	return s
+}
+
+// ContextWithRemoteSpanContext makes sc, usually from Extract, the parent of
+// the next span started from the returned context.
+func ContextWithRemoteSpanContext(ctx context.Context, sc SpanContext) context.Context {
+	return context.WithValue(ctx, spanContextKey{}, &Span{sc: sc, ended: true})
+}
+
+func (s *Span) SpanContext() SpanContext {
+	return s.sc
+}
+
+// SetName replaces the name given to Start e.g. once a route is matched.
+func (s *Span) SetName(name string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.ended {
+		s.data.Name = name
+	}
+}
+
+func (s *Span) SetKind(kind SpanKind) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.ended {
+		s.data.Kind = kind
+	}
+}
+
+func (s *Span) SetAttributes(fields ...Field) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.ended {
+		s.data.Attributes = append(s.data.Attributes, fields...)
+	}
+}
+
+func (s *Span) SetStatus(status SpanStatus, message string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.ended {
+		s.data.Status = status
+		s.data.StatusMessage = message
+	}
+}
+
+// End records the end time and queues sampled spans for export.
+func (s *Span) End() {
+	s.mu.Lock()
+	if s.ended {
+		s.mu.Unlock()
+		return
+	}
+	s.ended = true
+	s.data.End = time.Now()
+	data := s.data
+	s.mu.Unlock()
+
+	if s.tracer != nil && s.sc.Sampled() {
+		s.tracer.queue(data)
+	}
+}
+
+// ITracer starts spans. Spans are exported when they end.
+type ITracer interface {
+	Start(ctx context.Context, name string, fields ...Field) (context.Context, *Span)
+	Shutdown(ctx context.Context) error
+}
+
+type tracer struct {
+	service  string
+	exporter SpanExporter
+	spans    chan SpanData
+	mu       sync.RWMutex
+	closed   bool
+	wg       sync.WaitGroup
+}
+
+// spans are exported in batches of up to maxExportBatch or every exportInterval
+const (
+	maxExportBatch = 512
+	exportInterval = 5 * time.Second
+)
+
+// Tracer returns an ITracer exporting to exporter from a background
+// goroutine. A nil exporter only generates ids, which is enough to correlate
+// logs. Ended spans are dropped when the queue of 2048 is full.
+func Tracer(service string, exporter SpanExporter) ITracer {
+	t := &tracer{service: service, exporter: exporter}
+	if exporter != nil {
+		t.spans = make(chan SpanData, 2048)
+		t.wg.Add(1)
+		go t.run()
+	}
+	return t
+}
+
+// Start begins a span, a child of the span in ctx if there is one.
+func (t *tracer) Start(ctx context.Context, name string, fields ...Field) (context.Context, *Span) {
+	sc := SpanContext{Flags: flagSampled}
+	var parentID SpanID
+	if parent := SpanFromContext(ctx); parent != nil && parent.sc.IsValid() {
+		sc.TraceID = parent.sc.TraceID
+		sc.Flags = parent.sc.Flags
+		sc.TraceState = parent.sc.TraceState
+		parentID = parent.sc.SpanID
+	} else {
+		_, _ = rand.Read(sc.TraceID[:])
+	}
+	_, _ = rand.Read(sc.SpanID[:])
+
+	s := &Span{
+		tracer: t,
+		sc:     sc,
+		data: SpanData{
+			Service:     t.service,
+			Name:        name,
+			Kind:        SpanKindInternal,
+			SpanContext: sc,
+			Parent:      parentID,
+			Start:       time.Now(),
+			Attributes:  append([]Field{}, fields...),
+		},
+	}
+	return context.WithValue(ctx, spanContextKey{}, s), s
+}
+
+func (t *tracer) queue(data SpanData) {
+	if t.exporter == nil {
+		return
+	}
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	if t.closed {
+		return
+	}
+	select {
+	case t.spans <- data:
+	default:
+	}
+}
+
+func (t *tracer) run() {
+	defer t.wg.Done()
+
+	ticker := time.NewTicker(exportInterval)
+	defer ticker.Stop()
+
+	var batch []SpanData
+	for {
+		select {
+		case s, ok := <-t.spans:
+			if !ok {
+				t.export(batch)
+				return
+			}
+			batch = append(batch, s)
+			if len(batch) >= maxExportBatch {
+				t.export(batch)
+				batch = nil
+			}
+		case <-ticker.C:
+			t.export(batch)
+			batch = nil
+		}
+	}
+}
+
+func (t *tracer) export(batch []SpanData) {
+	if len(batch) == 0 {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), exportInterval)
+	defer cancel()
+	if err := t.exporter.Export(ctx, batch); err != nil {
+		fmt.Printf("%s tracer: dropped %d spans: %s\n", iCritical, len(batch), err.Error())
+	}
+}
+
+// Shutdown exports every ended span and shuts the exporter down. Register it
+// with OnShutdown so spans survive Fatal.
+func (t *tracer) Shutdown(ctx context.Context) error {
+	if t.exporter == nil {
+		return nil
+	}
+	t.mu.Lock()
+	if t.closed {
+		t.mu.Unlock()
+		return nil
+	}
+	t.closed = true
+	close(t.spans)
+	t.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		t.wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	return t.exporter.Shutdown(ctx)
+}
@@ -0,0 +1,216 @@
+package utils
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+)
+
+// collector is an OTLP/HTTP stand-in keeping every exported span.
+type collector struct {
+	mu       sync.Mutex
+	services []string
+	spans    []map[string]interface{}
+}
+
+func (c *collector) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var body struct {
+		ResourceSpans []struct {
+			Resource struct {
+				Attributes []struct {
+					Value struct {
+						StringValue string `json:"stringValue"`
+					} `json:"value"`
+				} `json:"attributes"`
+			} `json:"resource"`
+			ScopeSpans []struct {
+				Spans []map[string]interface{} `json:"spans"`
+			} `json:"scopeSpans"`
+		} `json:"resourceSpans"`
+	}
+	if r.URL.Path != "/v1/traces" || r.Header.Get("Content-Type") != "application/json" {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	by, _ := io.ReadAll(r.Body)
+	if err := json.Unmarshal(by, &body); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, rs := range body.ResourceSpans {
+		c.services = append(c.services, rs.Resource.Attributes[0].Value.StringValue)
+		for _, ss := range rs.ScopeSpans {
+			c.spans = append(c.spans, ss.Spans...)
+		}
+	}
+	w.WriteHeader(http.StatusOK)
+}
+
+func TestTraceparent(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		header string
+		valid  bool
+	}{
+		{"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01", true},
+		{"01-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-future", true},
+		{"00-4BF92F3577B34DA6A3CE929D0E0E4736-00f067aa0ba902b7-01", false},
+		{"00-00000000000000000000000000000000-00f067aa0ba902b7-01", false},
+		{"00-4bf92f3577b34da6a3ce929d0e0e4736-0000000000000000-01", false},
+		{"ff-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01", false},
+		{"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-extra", false},
+		{"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7", false},
+	}
+
+	for _, c := range cases {
+		// method to test
+		sc, err := ParseTraceparent(c.header)
+
+		// assert
+		if (err == nil) != c.valid {
+			t.Errorf("%s: expect valid %v, given %v", c.header, c.valid, err)
+		}
+		if c.valid && sc.Traceparent()[3:55] != c.header[3:55] {
+			t.Errorf("expect round trip of %s, given %s", c.header, sc.Traceparent())
+		}
+	}
+}
+
+func TestTracer(t *testing.T) {
+	t.Parallel()
+
+	t.Run("should export parent and child spans over otlp", func(t *testing.T) {
+		t.Parallel()
+
+		// given
+		c := &collector{}
+		srv := httptest.NewServer(c)
+		defer srv.Close()
+
+		exporter, err := OTLPExporter(OTLPConfig{Endpoint: srv.URL})
+		if err != nil {
+			t.Error(err.Error())
+			t.FailNow()
+		}
+		tr := Tracer("checkout", exporter)
+		lg := RecordingLogger()
+
+		// method to test
+		ctx, parent := tr.Start(context.Background(), "checkout")
+		ctx, child := tr.Start(ctx, "charge card", Int("attempt", 2))
+		lg.Log(ctx, "charging")
+		child.SetStatus(StatusError, "card declined")
+		child.End()
+		parent.End()
+		parent.SetAttributes(String("ignored", "after end"))
+
+		if err = tr.Shutdown(context.Background()); err != nil {
+			t.Error(err.Error())
+			t.FailNow()
+		}
+
+		// assert
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		if len(c.spans) != 2 || c.services[0] != "checkout" {
+			t.Errorf("expect 2 spans for checkout, given %v %v", c.services, c.spans)
+			t.FailNow()
+		}
+		exportedChild, exportedParent := c.spans[0], c.spans[1]
+		if exportedChild["traceId"] != exportedParent["traceId"] || exportedChild["parentSpanId"] != exportedParent["spanId"] {
+			t.Errorf("expect child of parent in one trace, given %v %v", exportedChild, exportedParent)
+		}
+		if _, ok := exportedParent["attributes"]; ok {
+			t.Errorf("expect no attributes set after end, given %v", exportedParent["attributes"])
+		}
+		attrs := exportedChild["attributes"].([]interface{})
+		if v := attrs[0].(map[string]interface{})["value"].(map[string]interface{}); v["intValue"] != "2" {
+			t.Errorf("expect int attribute as string, given %v", v)
+		}
+		if status := exportedChild["status"].(map[string]interface{}); status["code"] != float64(StatusError) {
+			t.Errorf("expect error status, given %v", status)
+		}
+
+		e := lg.Entries()[0]
+		if e.TraceId != exportedChild["traceId"] || e.SpanId != exportedChild["spanId"] {
+			t.Errorf("expect entry to carry the child span, given %s %s", e.TraceId, e.SpanId)
+		}
+	})
+
+	t.Run("should continue remote trace and inject it", func(t *testing.T) {
+		t.Parallel()
+
+		// given
+		in := http.Header{}
+		in.Set("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-00")
+		in.Set("tracestate", "congo=t61rcWkgMzE")
+		sc, ok := Extract(in)
+		if !ok {
+			t.Error("expect valid traceparent")
+			t.FailNow()
+		}
+
+		// method to test
+		ctx, span := Tracer("api", nil).Start(ContextWithRemoteSpanContext(context.Background(), sc), "GET")
+		out := http.Header{}
+		Inject(ctx, out)
+
+		// assert
+		got, err := ParseTraceparent(out.Get("traceparent"))
+		if err != nil {
+			t.Error(err.Error())
+			t.FailNow()
+		}
+		if got.TraceID != sc.TraceID || got.SpanID != span.SpanContext().SpanID || got.Sampled() {
+			t.Errorf("expect same unsampled trace with new span, given %s", out.Get("traceparent"))
+		}
+		if out.Get("tracestate") != "congo=t61rcWkgMzE" {
+			t.Errorf("expect tracestate passed on, given %s", out.Get("tracestate"))
+		}
+	})
+
+	t.Run("should report collector errors", func(t *testing.T) {
+		t.Parallel()
+
+		// given
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}))
+		defer srv.Close()
+		exporter, _ := OTLPExporter(OTLPConfig{Endpoint: srv.URL})
+
+		// method to test
+		err := exporter.Export(context.Background(), []SpanData{{Name: "span"}})
+
+		// assert
+		var se *statusError
+		if !errors.As(err, &se) || se.code != http.StatusServiceUnavailable {
+			t.Errorf("expect status error, given %v", err)
+		}
+	})
+
+	t.Run("should show the trace id in discord alerts", func(t *testing.T) {
+		t.Parallel()
+
+		// method to test
+		p := payload(&Entry{Status: iCritical, Info: "down", TraceId: "4bf92f3577b34da6a3ce929d0e0e4736"}, nil)
+
+		// assert
+		fields := p["embeds"].([]map[string]interface{})[0]["fields"].([]map[string]string)
+		for _, f := range fields {
+			if f["name"] == "Trace ID" && f["value"] == "4bf92f3577b34da6a3ce929d0e0e4736" {
+				return
+			}
+		}
+		t.Errorf("expect Trace ID embed field, given %v", fields)
+	})
+}
@@ -13,6 +13,9 @@ type RequestBody struct {
 	Ip     string `json:"ip_address,omitempty"`
 	Method string `json:"method,omitempty"`
 	Path   string `json:"path,omitempty"`
+	// TraceId and SpanId identify the server span of the request.
+	TraceId string `json:"trace_id,omitempty"`
+	SpanId  string `json:"span_id,omitempty"`
 }
 
 const RequestKey RequestContextKey = "REQUEST_KEY"
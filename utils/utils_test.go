@@ -21,12 +21,11 @@ func (d *helper) fn(_ *sql.Tx) error {
 func TestTransactionFunc(t *testing.T) {
 	t.Parallel()
 
-	lg := DevLogger("UTC")
-
 	t.Run("rollback. error starting transaction", func(t *testing.T) {
 		t.Parallel()
 
 		// given
+		lg := RecordingLogger()
 		h := helper{}
 		db, mock, err := sqlmock.New()
 		if err != nil {
@@ -58,12 +57,16 @@ func TestTransactionFunc(t *testing.T) {
 			t.Error("transaction func called but should not")
 			t.FailNow()
 		}
+
+		lg.AssertLogged(t, Query{Level: "CRITICAL", Message: "FAILED TO START TRANSACTION"})
+		lg.AssertNotLogged(t, Query{Message: "COMMITTING TRANSACTION"})
 	})
 
 	t.Run("commit tx. fn does not return error", func(t *testing.T) {
 		t.Parallel()
 
 		// given
+		lg := RecordingLogger()
 		h := helper{}
 		db, mock, err := sqlmock.New()
 		if err != nil {
@@ -91,12 +94,16 @@ func TestTransactionFunc(t *testing.T) {
 			t.Error("expect fn true, given false")
 			t.FailNow()
 		}
+
+		lg.AssertLogged(t, Query{Level: "LOG", Message: "TRANSACTION COMMITTED SUCCESSFULLY"})
+		lg.AssertNotLogged(t, Query{Message: "ROLLING BACK TRANSACTION"})
 	})
 
 	t.Run("rollback. fn does not return error but commit returns error", func(t *testing.T) {
 		t.Parallel()
 
 		// given
+		lg := RecordingLogger()
 		h := helper{}
 		db, mock, err := sqlmock.New()
 		if err != nil {
@@ -129,12 +136,15 @@ func TestTransactionFunc(t *testing.T) {
 			t.Errorf("expect %s, given %s", mess, err.Error())
 			t.FailNow()
 		}
+
+		lg.AssertLogged(t, Query{Level: "CRITICAL", Message: "FAILED TO COMMIT TRANSACTION"})
 	})
 
 	t.Run("rollback. fn returns error", func(t *testing.T) {
 		t.Parallel()
 
 		// given
+		lg := RecordingLogger()
 		str := "fn returns error"
 		h := helper{Error: errors.New(str)}
 		db, mock, err := sqlmock.New()
@@ -168,12 +178,17 @@ func TestTransactionFunc(t *testing.T) {
 			t.Errorf("expect %s, given %s", str, err.Error())
 			t.FailNow()
 		}
+
+		lg.AssertLogged(t, Query{Level: "ERROR", Message: "TRANSACTION FUNCTION RETURNED ERROR: " + str})
+		lg.AssertLogged(t, Query{Message: "ROLLING BACK TRANSACTION"})
+		lg.AssertLogged(t, Query{Message: "TRANSACTION ROLLED BACK SUCCESSFULLY"})
 	})
 
 	t.Run("rollback. fn returns error and rollback returns error", func(t *testing.T) {
 		t.Parallel()
 
 		// given
+		lg := RecordingLogger()
 		h := helper{Error: errors.New("fn returns error")}
 		db, mock, err := sqlmock.New()
 		if err != nil {
@@ -206,5 +221,8 @@ func TestTransactionFunc(t *testing.T) {
 			t.Errorf("expect %s, given %s", mess, err.Error())
 			t.FailNow()
 		}
+
+		lg.AssertLogged(t, Query{Message: "ROLLING BACK TRANSACTION"})
+		lg.AssertLogged(t, Query{Level: "CRITICAL", Message: "FAILED TO ROLLBACK TRANSACTION"})
 	})
 }